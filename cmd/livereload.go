@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const liveReloadPath = "/__goose/livereload"
+
+// liveReloadScript is only passed to the generator by the serve command, so it
+// never ends up in the output of goose generate.
+const liveReloadScript = `(function () {
+  var source = new EventSource("` + liveReloadPath + `");
+  source.addEventListener("reload", function () {
+    location.reload();
+  });
+  source.addEventListener("css", function () {
+    fetch(location.href, { cache: "no-store" })
+      .then(function (res) { return res.text(); })
+      .then(function (text) {
+        var doc = new DOMParser().parseFromString(text, "text/html");
+        var fresh = doc.querySelectorAll("style[data-goose-style]");
+        var current = document.querySelectorAll("style[data-goose-style]");
+        if (fresh.length !== current.length) {
+          location.reload();
+          return;
+        }
+        current.forEach(function (style, i) {
+          style.textContent = fresh[i].textContent;
+        });
+      })
+      .catch(function () {
+        location.reload();
+      });
+  });
+})();`
+
+type liveReloadBroker struct {
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+}
+
+func newLiveReloadBroker() *liveReloadBroker {
+	return &liveReloadBroker{clients: make(map[chan string]struct{})}
+}
+
+func (b *liveReloadBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events := make(chan string, 1)
+	b.mu.Lock()
+	b.clients[events] = struct{}{}
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, events)
+		b.mu.Unlock()
+	}()
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: {}\n\n", event)
+			flusher.Flush()
+		}
+	}
+}
+
+// notify sends event ("reload" or "css") to every connected browser tab.
+func (b *liveReloadBroker) notify(event string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for client := range b.clients {
+		select {
+		case client <- event:
+		default: // an event is already pending for this tab
+		}
+	}
+}
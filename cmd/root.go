@@ -4,25 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/radeeyate/goose/generator"
 )
 
 var cfgFile string
 
-const (
-	defaultSourceDir     = "source"
-	defaultBuildDir      = "build"
-	defaultPagesDir      = "pages"
-	defaultStylesDir     = "styles"
-	defaultScriptsDir    = "scripts"
-	defaultTemplatesDir  = "templates"
-	defaultStaticDir     = "static"
-	defaultTemplate      = "default.html"
-	defaultStyle         = "github"
-	defaultHtmxSourceURL = "https://unpkg.com/htmx.org@2.0.4"
-)
+const defaultServePort = 8080
+
+// defaultCacheDir is where goose generate keeps its build cache; libraries
+// get no cache unless they ask for one.
+const defaultCacheDir = ".goose/cache"
+
+// defaults are the settings used when neither the config file nor a flag sets
+// them.
+var defaults = generator.DefaultConfig()
 
 func init() {
 
@@ -32,12 +32,31 @@ func init() {
 		StringVar(&cfgFile, "config", "", "config file (default is ./goose.toml or $HOME/.goose/goose.toml)")
 
 	rootCmd.PersistentFlags().
-		StringP("source", "s", defaultSourceDir, "Source directory containing website content")
+		StringP("source", "s", defaults.SourceDir, "Source directory containing website content")
+	rootCmd.PersistentFlags().
+		StringP("build", "b", defaults.BuildDir, "Directory to output the generated website")
+
+	rootCmd.PersistentFlags().
+		StringP("environment", "e", defaults.Environment, "Environment to build for; goose.<environment>.toml is merged over the config (also GOOSE_ENV)")
+
+	rootCmd.PersistentFlags().
+		IntP("jobs", "j", defaults.Jobs, "Number of pages to render in parallel")
 	rootCmd.PersistentFlags().
-		StringP("build", "b", defaultBuildDir, "Directory to output the generated website")
+		Bool("strict", false, "Treat warnings (missing stylesheets, scripts or templates, skipped pages) as errors")
+
+	rootCmd.PersistentFlags().
+		Bool("buildFuture", false, "Include pages with a publishDate or date in the future")
+	rootCmd.PersistentFlags().
+		Bool("buildExpired", false, "Include pages whose expiryDate has passed")
 
 	viper.BindPFlag("sourceDir", rootCmd.PersistentFlags().Lookup("source"))
 	viper.BindPFlag("buildDir", rootCmd.PersistentFlags().Lookup("build"))
+	viper.BindPFlag("environment", rootCmd.PersistentFlags().Lookup("environment"))
+	viper.BindEnv("environment", "GOOSE_ENV")
+	viper.BindPFlag("jobs", rootCmd.PersistentFlags().Lookup("jobs"))
+	viper.BindPFlag("strict", rootCmd.PersistentFlags().Lookup("strict"))
+	viper.BindPFlag("buildFuture", rootCmd.PersistentFlags().Lookup("buildFuture"))
+	viper.BindPFlag("buildExpired", rootCmd.PersistentFlags().Lookup("buildExpired"))
 
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(serveCmd)
@@ -56,29 +75,50 @@ func initConfig() {
 		viper.SetConfigType("toml")
 	}
 
-	viper.SetDefault("sourceDir", defaultSourceDir)
-	viper.SetDefault("buildDir", defaultBuildDir)
-	viper.SetDefault("pagesDir", defaultPagesDir)
-	viper.SetDefault("stylesDir", defaultStylesDir)
-	viper.SetDefault("scriptsDir", defaultScriptsDir)
-	viper.SetDefault("templatesDir", defaultTemplatesDir)
-	viper.SetDefault("staticDir", defaultStaticDir)
-	viper.SetDefault("syntaxHighlightingStyle", defaultStyle)
-	viper.SetDefault("defaultTemplate", defaultTemplate)
-	viper.SetDefault("defaultStyles", []string{"default.css"})
-	viper.SetDefault("defaultScripts", []string{"default.js"})
-	viper.SetDefault("minifyOutput", true)
-	viper.SetDefault("enableHtmx", true)
-	viper.SetDefault("addHxBoost", true)
-	viper.SetDefault("htmxSourceURL", defaultHtmxSourceURL)
-	viper.SetDefault("includeDrafts", false)
+	viper.SetDefault("sourceDir", defaults.SourceDir)
+	viper.SetDefault("buildDir", defaults.BuildDir)
+	viper.SetDefault("pagesDir", defaults.PagesDir)
+	viper.SetDefault("stylesDir", defaults.StylesDir)
+	viper.SetDefault("scriptsDir", defaults.ScriptsDir)
+	viper.SetDefault("templatesDir", defaults.TemplatesDir)
+	viper.SetDefault("staticDir", defaults.StaticDir)
+	viper.SetDefault("syntaxHighlightingStyle", defaults.SyntaxHighlightingStyle)
+	viper.SetDefault("defaultTemplate", defaults.DefaultTemplate)
+	viper.SetDefault("defaultStyles", defaults.DefaultStyles)
+	viper.SetDefault("defaultScripts", defaults.DefaultScripts)
+	viper.SetDefault("minifyOutput", defaults.MinifyOutput)
+	viper.SetDefault("enableHtmx", defaults.EnableHtmx)
+	viper.SetDefault("addHxBoost", defaults.AddHxBoost)
+	viper.SetDefault("htmxSourceURL", defaults.HtmxSourceURL)
+	viper.SetDefault("includeDrafts", defaults.IncludeDrafts)
 	viper.SetDefault("markdownPlaceholderTag", "markdown")
-	viper.SetDefault("prettyURLs", true)
-	viper.SetDefault("defaultMetadata", map[string]interface{}{})
-	viper.SetDefault("syntaxHighlightingUseCustomBackground", false)
-	viper.SetDefault("syntaxHighlightingCustomBackground", "")
-	viper.SetDefault("enableCodeBlockLineNumbers", true)
-	viper.SetDefault("enableEmoji", true)
+	viper.SetDefault("prettyURLs", defaults.PrettyURLs)
+	viper.SetDefault("taxonomies", defaults.Taxonomies)
+	viper.SetDefault("sectionSort", defaults.SectionSort)
+	viper.SetDefault("relatedCount", defaults.RelatedCount)
+	weights := make(map[string]interface{})
+	for key, weight := range defaults.RelatedWeights {
+		weights[key] = weight
+	}
+	viper.SetDefault("relatedWeights", weights)
+	viper.SetDefault("paginate", defaults.Paginate)
+	viper.SetDefault("feedLimit", defaults.FeedLimit)
+	viper.SetDefault("sitemap", defaults.Sitemap)
+	viper.SetDefault("robotsTxt", defaults.RobotsTxt)
+	viper.SetDefault("defaultMetadata", defaults.DefaultMetadata)
+	viper.SetDefault("syntaxHighlightingUseCustomBackground", defaults.SyntaxHighlightingUseCustomBackground)
+	viper.SetDefault("syntaxHighlightingCustomBackground", defaults.SyntaxHighlightingCustomBackground)
+	viper.SetDefault("enableCodeBlockLineNumbers", defaults.EnableCodeBlockLineNumbers)
+	viper.SetDefault("enableEmoji", defaults.EnableEmoji)
+	viper.SetDefault("tocMinLevel", defaults.TOCMinLevel)
+	viper.SetDefault("tocMaxLevel", defaults.TOCMaxLevel)
+	viper.SetDefault("summaryLength", defaults.SummaryLength)
+	viper.SetDefault("wordsPerMinute", defaults.WordsPerMinute)
+	viper.SetDefault("servePort", defaultServePort)
+	viper.SetDefault("cacheDir", defaultCacheDir)
+	viper.SetDefault("jobs", defaults.Jobs)
+	viper.SetDefault("strict", defaults.Strict)
+	viper.SetDefault("environment", defaults.Environment)
 
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
@@ -89,6 +129,46 @@ func initConfig() {
 			fmt.Printf("Error reading config file %s: %v\n", viper.ConfigFileUsed(), err)
 		}
 	}
+
+	mergeEnvironmentConfig(viper.GetString("environment"))
+}
+
+// mergeEnvironmentConfig deep-merges goose.<env>.toml over the config that
+// has been read so far. The overlay is looked for next to the base config
+// file, or in the usual config paths if there is none.
+func mergeEnvironmentConfig(env string) {
+	if env == "" {
+		return
+	}
+
+	var candidates []string
+	if base := viper.ConfigFileUsed(); base != "" {
+		if _, err := os.Stat(base); err == nil {
+			ext := filepath.Ext(base)
+			candidates = append(candidates, strings.TrimSuffix(base, ext)+"."+env+ext)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, "goose."+env+".toml")
+		if home, err := os.UserHomeDir(); err == nil {
+			candidates = append(candidates, filepath.Join(home, ".goose", "goose."+env+".toml"))
+		}
+	}
+
+	for _, overlay := range candidates {
+		if _, err := os.Stat(overlay); err != nil {
+			continue
+		}
+
+		viper.SetConfigFile(overlay)
+		if err := viper.MergeInConfig(); err != nil {
+			fmt.Printf("Error reading config file %s: %v\n", overlay, err)
+			return
+		}
+
+		fmt.Printf("Using %s config file: %s\n", env, overlay)
+		return
+	}
 }
 
 var rootCmd = &cobra.Command{
@@ -2,14 +2,197 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/radeeyate/goose/generator"
+	"github.com/radeeyate/goose/helpers"
 )
 
+const rebuildDelay = 100 * time.Millisecond
+
 func init() {
 	serveCmd.Run = runServe
+
+	serveCmd.Flags().IntP("port", "p", defaultServePort, "Port to serve the built site on")
+	serveCmd.Flags().Bool("memory", false, "Keep the built site in memory instead of writing it to the build directory")
+
+	viper.BindPFlag("servePort", serveCmd.Flags().Lookup("port"))
+	viper.BindPFlag("serveFromMemory", serveCmd.Flags().Lookup("memory"))
 }
 
 func runServe(cmd *cobra.Command, args []string) {
-	fmt.Println("Serve command called (implementation pending)...")
+	sourceDir := viper.GetString("sourceDir")
+	buildDir := viper.GetString("buildDir")
+	port := viper.GetInt("servePort")
+
+	if exists, err := helpers.IsDir(sourceDir); !exists || err != nil {
+		log.Fatalf("%s directory not found.", sourceDir)
+	}
+
+	broker := newLiveReloadBroker()
+
+	// the cache is for generate; serve rebuilds incrementally instead
+	config := loadConfig()
+	config.CacheDir = ""
+	config.LiveReloadScript = liveReloadScript
+
+	served, site := buildDir, http.FileServer(http.Dir(buildDir))
+	if viper.GetBool("serveFromMemory") {
+		served = "the site from memory"
+		output := generator.NewMemoryOutput()
+		config.Output = output
+		site = http.FileServerFS(output)
+	}
+
+	// only the first build is interrupted by Ctrl+C, so that it throws its
+	// stage away; after that, Ctrl+C stops the server as usual
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	b := generator.New(config)
+	safeBuild(func() error {
+		return b.Build(ctx)
+	})
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+	stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal("Error creating file watcher:", err)
+	}
+	defer watcher.Close()
+
+	if err := watchDir(watcher, sourceDir, buildDir); err != nil {
+		log.Fatalf("Error watching %s: %v", sourceDir, err)
+	}
+
+	stylesDir := filepath.Join(sourceDir, viper.GetString("stylesDir"))
+	go watchSource(watcher, buildDir, func(changed []string) {
+		// open tabs keep their state unless there is something new to see
+		var wrote bool
+		ok := safeBuild(func() (err error) {
+			wrote, err = b.Rebuild(changed)
+			return err
+		})
+		if !ok || !wrote {
+			return
+		}
+
+		if onlyStylesheets(changed, stylesDir) {
+			broker.notify("css")
+		} else {
+			broker.notify("reload")
+		}
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(liveReloadPath, broker)
+	mux.Handle("/", site)
+
+	fmt.Printf("Serving %s at http://localhost:%d (watching %s, press Ctrl+C to stop)\n", served, port, sourceDir)
+
+	err = http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+	if err != nil {
+		log.Fatal("Error starting server:", err)
+	}
+}
+
+// safeBuild runs a build, keeping the server alive if a page fails to build,
+// and reports whether it succeeded.
+func safeBuild(build func() error) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Build failed: %v\n", r)
+			ok = false
+		}
+	}()
+
+	if err := build(); err != nil {
+		log.Printf("Build failed: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func watchDir(watcher *fsnotify.Watcher, root, buildDir string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if helpers.IsWithin(path, buildDir) {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+func watchSource(watcher *fsnotify.Watcher, buildDir string, onChange func(changed []string)) {
+	debounce := time.NewTimer(rebuildDelay)
+	debounce.Stop()
+
+	var changed []string
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op == fsnotify.Chmod || helpers.IsWithin(event.Name, buildDir) {
+				continue
+			}
+
+			if event.Has(fsnotify.Create) {
+				if exists, err := helpers.IsDir(event.Name); exists && err == nil {
+					if err := watchDir(watcher, event.Name, buildDir); err != nil {
+						log.Printf("Error watching %s: %v\n", event.Name, err)
+					}
+				}
+			}
+
+			fmt.Printf("Change detected: %s\n", event.Name)
+			changed = append(changed, event.Name)
+			debounce.Reset(rebuildDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Watcher error:", err)
+		case <-debounce.C:
+			onChange(changed)
+			changed = nil
+		}
+	}
+}
+
+// onlyStylesheets reports whether every changed path is a stylesheet, in which
+// case open tabs can swap their CSS instead of reloading.
+func onlyStylesheets(changed []string, stylesDir string) bool {
+	if len(changed) == 0 {
+		return false
+	}
+
+	for _, path := range changed {
+		if filepath.Ext(path) != ".css" || !helpers.IsWithin(path, stylesDir) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+const redirectTemplate = `<!DOCTYPE html><html><head><meta charset="utf-8"><title>%[1]s</title>` +
+	`<link rel="canonical" href="%[1]s"><meta name="robots" content="noindex">` +
+	`<meta http-equiv="refresh" content="0; url=%[1]s"></head></html>`
+
+// aliases writes a redirect page for every old URL listed in the aliases
+// front matter of a page, and optionally a _redirects file and an nginx map
+// with all of them.
+type aliases struct {
+	redirectsFile bool
+	nginxMap      bool
+}
+
+type alias struct {
+	from, to string
+}
+
+func (aliases) Name() string { return "aliases" }
+
+// PlannedOutputs lets alias collisions be caught by the global output check.
+func (h aliases) PlannedOutputs(site *Site) []PlannedOutput {
+	var planned []PlannedOutput
+	for _, page := range site.Pages {
+		for _, from := range pageAliases(page) {
+			planned = append(planned, PlannedOutput{Name: urlFile(from), Source: page.SourcePath})
+		}
+	}
+
+	if h.redirectsFile {
+		planned = append(planned, PlannedOutput{Name: "_redirects", Source: "the redirectsFile setting"})
+	}
+	if h.nginxMap {
+		planned = append(planned, PlannedOutput{Name: "nginx-redirects.conf", Source: "the nginxRedirectMap setting"})
+	}
+	return planned
+}
+
+func (h aliases) AfterSite(site *Site, out FileWriter) error {
+	var errs []error
+	var redirects []alias
+	for _, page := range site.Pages {
+		for _, from := range pageAliases(page) {
+			from = "/" + strings.TrimPrefix(from, "/")
+			name := urlFile(from)
+
+			if err := out.WriteFile(name, []byte(fmt.Sprintf(redirectTemplate, html.EscapeString(page.URL)))); err != nil {
+				errs = append(errs, newBuildError(page.SourcePath, stageWrite, name, err))
+				continue
+			}
+			redirects = append(redirects, alias{from: from, to: page.URL})
+		}
+	}
+
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].from < redirects[j].from
+	})
+
+	if h.redirectsFile {
+		var redirectsFile strings.Builder
+		for _, redirect := range redirects {
+			fmt.Fprintf(&redirectsFile, "%s %s 301\n", redirect.from, redirect.to)
+		}
+		if err := out.WriteFile("_redirects", []byte(redirectsFile.String())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if h.nginxMap {
+		var nginxMap strings.Builder
+		nginxMap.WriteString("map $uri $goose_redirect {\n")
+		for _, redirect := range redirects {
+			fmt.Fprintf(&nginxMap, "    %s %s;\n", redirect.from, redirect.to)
+		}
+		nginxMap.WriteString("}\n")
+		if err := out.WriteFile("nginx-redirects.conf", []byte(nginxMap.String())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pageAliases returns the aliases front matter of a page, which is either a
+// list or a single URL.
+func pageAliases(page *Page) []string {
+	switch value := page.Meta["aliases"].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		aliases := make([]string, 0, len(value))
+		for _, alias := range value {
+			aliases = append(aliases, fmt.Sprintf("%v", alias))
+		}
+		return aliases
+	}
+	return nil
+}
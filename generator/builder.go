@@ -0,0 +1,548 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// Builder builds a site from a Config. It keeps the pages and dependencies of
+// its last build, so that Rebuild only has to redo what changed.
+type Builder struct {
+	config Config
+
+	pagesDir     string
+	stylesDir    string
+	scriptsDir   string
+	templatesDir string
+	staticDir    string
+
+	defaultMetadata map[string]interface{}
+	permalinks      map[string]string
+	baseURL         string // without a trailing slash
+	location        *time.Location
+
+	// configErr is a problem with the config, returned by Build and Load
+	configErr error
+
+	source fs.FS
+	output Output
+	out    FileWriter // output, or the stage of a full build
+	hooks  []Hook
+
+	log    io.Writer
+	logger *log.Logger
+	site   *Site
+	loaded bool
+	deps   *depGraph
+
+	// published is set once a Build has replaced the output, which Rebuild
+	// needs to write into
+	published bool
+
+	cache  *buildCache
+	report *buildReport
+}
+
+// New returns a Builder for config.
+func New(config Config) *Builder {
+	b := &Builder{
+		config:       config,
+		pagesDir:     filepath.Join(config.SourceDir, config.PagesDir),
+		stylesDir:    filepath.Join(config.SourceDir, config.StylesDir),
+		scriptsDir:   filepath.Join(config.SourceDir, config.ScriptsDir),
+		templatesDir: filepath.Join(config.SourceDir, config.TemplatesDir),
+		staticDir:    filepath.Join(config.SourceDir, config.StaticDir),
+		source:       config.Source,
+		output:       config.Output,
+		log:          config.Log,
+		site:         newSite(nil),
+		deps:         newDepGraph(),
+		report:       newBuildReport(config.Strict),
+	}
+
+	b.defaultMetadata = make(map[string]interface{}, len(config.DefaultMetadata)+1)
+	if config.Environment != "" {
+		b.defaultMetadata["environment"] = config.Environment
+	}
+	for key, value := range config.DefaultMetadata {
+		b.defaultMetadata[key] = value
+	}
+
+	b.permalinks = make(map[string]string, len(config.Permalinks))
+	for section, pattern := range config.Permalinks {
+		b.permalinks[strings.ToLower(section)] = pattern
+	}
+
+	b.baseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		location = time.UTC
+		b.configErr = fmt.Errorf("invalid timezone: %w", err)
+	}
+	b.location = location
+	b.hooks = append(b.builtinHooks(), config.Hooks...)
+
+	if b.source == nil {
+		b.source = os.DirFS(config.SourceDir)
+	}
+	if b.output == nil {
+		b.output = NewDirOutput(config.BuildDir)
+	}
+	b.out = b.output
+
+	if b.log == nil {
+		b.log = os.Stdout
+	}
+	b.logger = log.New(b.log, "", log.LstdFlags)
+
+	b.openCache()
+
+	return b
+}
+
+// openCache reads the cache manifest as the last Build left it.
+func (b *Builder) openCache() {
+	if b.config.CacheDir != "" {
+		b.cache = newBuildCache(b.config, hookNames(b.hooks), b.readFile, b.logger)
+	}
+}
+
+// Build builds the whole site into a stage of the output and only publishes it
+// once every page has been built, so a failed build never leaves the output
+// empty or half-written. If any page fails, the returned error is a
+// BuildErrors.
+func (b *Builder) Build(ctx context.Context) (err error) {
+	fmt.Fprintln(b.log, "Starting static site generation...")
+
+	cfg := b.config
+	b.deps = newDepGraph()
+	b.report = newBuildReport(cfg.Strict)
+	b.published = false
+	b.openCache()
+
+	if cfg.SyntaxHighlightingUseCustomBackground && cfg.SyntaxHighlightingCustomBackground == "" {
+		b.logger.Println(
+			"Warning: syntaxHighlightingUseCustomBackground is set to true, but no custom background color was provided. Using default.",
+		)
+	}
+
+	if b.configErr != nil {
+		return b.configErr
+	}
+
+	if exists, err := b.isDir(cfg.SourceDir); !exists && err != nil {
+		return fmt.Errorf("%s directory not found", cfg.SourceDir)
+	}
+
+	if exists, err := b.isDir(b.pagesDir); !exists && err != nil {
+		return fmt.Errorf("%s directory not found", b.pagesDir)
+	}
+
+	stage, err := b.output.Stage()
+	if err != nil {
+		return fmt.Errorf("error creating build directory: %w", err)
+	}
+
+	b.out = stage
+	defer func() {
+		b.out = b.output
+
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			stage.Discard()
+			err = fmt.Errorf("build failed, leaving %s untouched: %w", cfg.BuildDir, err)
+		}
+	}()
+
+	pages, err := b.findPages()
+	if err != nil {
+		return err
+	}
+
+	b.site = b.loadSite(ctx, pages)
+	b.generatePages()
+	b.loaded = true
+
+	// nothing is written if two sources would end up at the same path
+	if b.checkOutputs() {
+		if exists, err := b.isDir(b.staticDir); exists && err == nil {
+			err = b.walkFiles(b.staticDir, b.copyStatic)
+			if err != nil {
+				b.report.fail("", newBuildError("", stageWrite, b.staticDir, err))
+			}
+		}
+
+		b.renderPages(ctx, b.site.allPages())
+		b.afterSite(stage)
+	}
+
+	// the pages an interrupted build skipped are not worth reporting
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.report.print(b.log)
+	if err := b.report.err(); err != nil {
+		return err
+	}
+
+	if err := stage.Publish(); err != nil {
+		return err
+	}
+	b.published = true
+
+	if b.cache != nil {
+		b.cache.save()
+	}
+
+	fmt.Fprintln(b.log, "\nStatic site generation complete!")
+	return nil
+}
+
+// Load reads every page and its front matter without rendering anything, so
+// the site can be inspected with Pages.
+func (b *Builder) Load(ctx context.Context) error {
+	if b.configErr != nil {
+		return b.configErr
+	}
+
+	b.deps = newDepGraph()
+	b.report = newBuildReport(b.config.Strict)
+
+	pages, err := b.findPages()
+	if err != nil {
+		return err
+	}
+
+	b.site = b.loadSite(ctx, pages)
+	b.generatePages()
+	b.loaded = true
+
+	b.report.print(b.log)
+	return b.report.err()
+}
+
+// Pages returns the pages of the last Build or Load, sorted by source path.
+func (b *Builder) Pages() []*Page {
+	return b.site.Pages
+}
+
+// BuildPage renders a single page straight into the output. path is relative to
+// the pages directory, e.g. "blog/first.md". The site is loaded first if
+// neither Build nor Load has been called yet.
+func (b *Builder) BuildPage(path string) error {
+	if !b.loaded {
+		if err := b.Load(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	page := b.site.page(filepath.Join(b.pagesDir, path))
+	if page == nil {
+		return fmt.Errorf("%s is not a page of this site", path)
+	}
+
+	b.report = newBuildReport(b.config.Strict)
+	b.renderPages(context.Background(), []*Page{page})
+
+	b.report.print(b.log)
+	return b.report.err()
+}
+
+// findPages lists the Markdown files in the pages directory.
+func (b *Builder) findPages() ([]string, error) {
+	var pages []string
+	err := b.walkFiles(b.pagesDir, func(path string) error {
+		if filepath.Ext(path) == ".md" {
+			pages = append(pages, path)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %q: %w", b.pagesDir, err)
+	}
+
+	return pages, nil
+}
+
+// renderPages runs the second phase of a build, rendering every loaded page.
+// Failed pages are recorded in the build report.
+func (b *Builder) renderPages(ctx context.Context, pages []*Page) {
+	// files may have changed since the last pages were rendered
+	if b.cache != nil {
+		b.cache.forgetHashes()
+	}
+
+	errs := b.runJobs(ctx, len(pages), func(i int, logs io.Writer) error {
+		return b.renderPage(pages[i], logs)
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			b.report.fail(pages[i].SourcePath, err)
+		}
+	}
+}
+
+// runJobs calls job for 0..n-1 across a pool of cfg.Jobs workers and returns
+// the error of each job. Each job logs into its own buffer, and the buffers
+// are printed in order so the output reads the same as a serial build. Jobs
+// that have not started by the time ctx is cancelled fail with ctx.Err().
+func (b *Builder) runJobs(ctx context.Context, n int, job func(i int, logs io.Writer) error) []error {
+	jobs := b.config.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	logs := make([]bytes.Buffer, n)
+	done := make([]chan error, n)
+	for i := range done {
+		done[i] = make(chan error, 1)
+	}
+
+	queue := make(chan int)
+	go func() {
+		for i := range n {
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	for range jobs {
+		go func() {
+			for i := range queue {
+				if err := ctx.Err(); err != nil {
+					done[i] <- err
+					continue
+				}
+				done[i] <- runJob(i, &logs[i], job)
+			}
+		}()
+	}
+
+	errs := make([]error, n)
+	for i := range n {
+		errs[i] = <-done[i]
+		b.log.Write(logs[i].Bytes())
+	}
+
+	return errs
+}
+
+// runJob logs the error a job returns, and turns a panic into an error
+// instead of letting it take down the worker pool.
+func runJob(i int, logs io.Writer, job func(i int, logs io.Writer) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			fmt.Fprintf(logs, "failed: %v\n", err)
+		}
+	}()
+
+	return job(i, logs)
+}
+
+// Rebuild regenerates only the pages affected by the changed paths, removes
+// the outputs of pages whose source was deleted and refreshes changed static
+// files. It relies on a previous Build and writes straight into the output,
+// so it runs a full Build instead when the last one failed. It reports
+// whether it wrote anything, which it does not for changes no page depends
+// on, such as an editor's swap files.
+func (b *Builder) Rebuild(changed []string) (bool, error) {
+	cfg := b.config
+
+	if !b.published {
+		fmt.Fprintln(b.log, "The last build failed, building the whole site again...")
+		err := b.Build(context.Background())
+		return b.published, err
+	}
+
+	wrote := false
+
+	pages := make(map[string]bool)
+	for _, path := range changed {
+		path = filepath.Clean(path)
+
+		for _, page := range b.deps.dependents(path) {
+			pages[page] = true
+		}
+
+		if helpers.IsWithin(path, b.staticDir) {
+			b.refreshStatic(path)
+			wrote = true
+		}
+
+		if !helpers.IsWithin(path, b.pagesDir) {
+			continue
+		}
+
+		if exists, err := b.isDir(path); exists && err == nil {
+			b.walkFiles(path, func(path string) error {
+				if filepath.Ext(path) == ".md" {
+					pages[path] = true
+				}
+				return nil
+			})
+		} else if filepath.Ext(path) == ".md" {
+			pages[path] = true
+		}
+	}
+
+	if len(pages) == 0 {
+		return wrote, nil
+	}
+
+	b.report = newBuildReport(cfg.Strict)
+
+	sorted := make([]string, 0, len(pages))
+	for page := range pages {
+		sorted = append(sorted, page)
+	}
+	sort.Strings(sorted)
+
+	fmt.Fprintf(b.log, "Rebuilding %d page(s)...\n", len(sorted))
+
+	// reload every affected page first, so pages rendered below see the
+	// current front matter of the others, and so a page that was shadowed by
+	// a deleted one can take over its output path
+	var render []*Page
+	for _, path := range sorted {
+		if b.site.generatedPage(path) != nil {
+			continue // rendered again below
+		}
+
+		if exists, err := b.isFile(path); !exists || err != nil {
+			b.site.remove(path)
+			if outPath := b.deps.forget(path); outPath != "" {
+				fmt.Fprintf(b.log, "Removing %s\n", outPath)
+				if err := b.output.Remove(b.outputName(outPath)); err != nil {
+					b.logger.Printf("Error removing %s: %v\n", outPath, err)
+				}
+			}
+			continue
+		}
+
+		page, err := b.loadPage(path, b.log)
+		if err != nil {
+			fmt.Fprintf(b.log, "Loading %s... failed: %v\n", path, err)
+			b.report.fail(path, err)
+		}
+
+		if page != nil {
+			b.site.add(page)
+			render = append(render, page)
+		} else {
+			b.site.remove(path)
+		}
+	}
+
+	linked := make(map[string][]string)
+	for _, page := range b.site.Pages {
+		linked[page.SourcePath] = linkedSources(page)
+	}
+	fingerprint := b.site.fingerprint
+
+	// any change can add or remove pages from a list, so section and
+	// generated pages are always worked out and rendered again, along with
+	// the pages whose neighbours or related pages changed, and every page
+	// that can reach other pages when any of them changed
+	previous := b.site.generated
+	for _, page := range b.generatePages() {
+		if !slices.Contains(render, page) {
+			render = append(render, page)
+		}
+	}
+	for _, page := range b.site.Pages {
+		changed := !slices.Equal(linked[page.SourcePath], linkedSources(page)) ||
+			fingerprint != b.site.fingerprint && b.readsSite(page)
+		if changed && !slices.Contains(render, page) {
+			render = append(render, page)
+		}
+	}
+	for _, page := range previous {
+		if b.site.generatedPage(page.SourcePath) == nil {
+			if outPath := b.deps.forget(page.SourcePath); outPath != "" {
+				fmt.Fprintf(b.log, "Removing %s\n", outPath)
+				if err := b.output.Remove(b.outputName(outPath)); err != nil {
+					b.logger.Printf("Error removing %s: %v\n", outPath, err)
+				}
+			}
+		}
+	}
+
+	if b.checkOutputs() {
+		b.renderPages(context.Background(), render)
+		b.afterSite(b.output)
+	}
+
+	b.report.print(b.log)
+	if err := b.report.err(); err != nil {
+		return true, err
+	}
+
+	fmt.Fprintln(b.log, "Rebuild complete!")
+	return true, nil
+}
+
+// refreshStatic mirrors a single changed path from staticDir into the output.
+func (b *Builder) refreshStatic(path string) {
+	relPath, err := filepath.Rel(b.staticDir, path)
+	if err != nil {
+		b.logger.Printf("Error calculating relative path for %s: %v\n", path, err)
+		return
+	}
+
+	name := filepath.ToSlash(filepath.Join("static", relPath))
+	if err := b.output.Remove(name); err != nil {
+		b.logger.Printf("Error removing %s: %v\n", name, err)
+		return
+	}
+
+	info, err := b.stat(path)
+	if err != nil {
+		return // deleted
+	}
+
+	if info.IsDir() {
+		err = b.walkFiles(path, b.copyStatic)
+	} else {
+		err = b.copyStatic(path)
+	}
+
+	if err != nil {
+		b.logger.Printf("Error copying static file %s: %v\n", path, err)
+	}
+}
+
+// copyStatic copies a file from staticDir into the output.
+func (b *Builder) copyStatic(path string) error {
+	relPath, err := filepath.Rel(b.staticDir, path)
+	if err != nil {
+		return err
+	}
+
+	content, err := b.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	return b.out.WriteFile(filepath.ToSlash(filepath.Join("static", relPath)), content)
+}
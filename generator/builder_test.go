@@ -0,0 +1,337 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testTemplate = `<!doctype html>
+<html><head></head><body>
+<main>{{ .Markdown }}</main>
+<ul class="site">{{ range .Site.Pages }}<li>{{ .Title }}</li>{{ end }}</ul>
+<p class="nav">{{ with .Page.Prev }}prev={{ .Title }}{{ end }} {{ with .Page.Next }}next={{ .Title }}{{ end }}</p>
+<ul class="related">{{ range .Page.Related }}<li>{{ .Title }}</li>{{ end }}</ul>
+</body></html>`
+
+// testSource returns a site with the given pages, named relative to the
+// pages directory, along with the files every page needs.
+func testSource(pages map[string]string) fstest.MapFS {
+	source := fstest.MapFS{
+		"templates/default.html": {Data: []byte(testTemplate)},
+		"styles/default.css":     {Data: []byte("body { margin: 0; }")},
+		"scripts/default.js":     {Data: []byte("console.log(1);")},
+	}
+	for name, content := range pages {
+		source["pages/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return source
+}
+
+func testBuilder(source fstest.MapFS, cacheDir string) (*Builder, *MemoryOutput) {
+	cfg := DefaultConfig()
+	cfg.Source = source
+	cfg.CacheDir = cacheDir
+	cfg.MinifyOutput = false
+	cfg.EnableHtmx = false
+	cfg.Log = io.Discard
+
+	out := NewMemoryOutput()
+	cfg.Output = out
+	return New(cfg), out
+}
+
+func readOutput(t *testing.T, out *MemoryOutput, name string) string {
+	t.Helper()
+
+	content, err := fs.ReadFile(out, name)
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestBuildReusesCacheAcrossBuilds(t *testing.T) {
+	cacheDir := t.TempDir()
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nfirst version\n",
+	})
+
+	// leave a manifest behind, as an earlier goose generate would
+	first, _ := testBuilder(source, cacheDir)
+	if err := first.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, out := testBuilder(source, cacheDir)
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/a.md"] = &fstest.MapFile{Data: []byte("---\ntitle: A\n---\nsecond version\n")}
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "a/index.html"); !strings.Contains(got, "second version") {
+		t.Errorf("a/index.html was served from the cache after a.md changed:\n%s", got)
+	}
+}
+
+func TestRebuildAfterFailedBuild(t *testing.T) {
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nA\n",
+		"b.md": "---\ntitle: [B\n---\nB\n",
+	})
+
+	b, out := testBuilder(source, "")
+	if err := b.Build(context.Background()); err == nil {
+		t.Fatal("Build succeeded with broken front matter in b.md")
+	}
+
+	source["pages/b.md"] = &fstest.MapFile{Data: []byte("---\ntitle: B\n---\nB\n")}
+	if _, err := b.Rebuild([]string{filepath.Join("source", "pages", "b.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a/index.html", "b/index.html"} {
+		readOutput(t, out, name)
+	}
+}
+
+func TestCachedPagesSeeNewPages(t *testing.T) {
+	cacheDir := t.TempDir()
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nA\n",
+	})
+
+	first, _ := testBuilder(source, cacheDir)
+	if err := first.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/zed.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Zed Extra\n---\nZ\n")}
+
+	b, out := testBuilder(source, cacheDir)
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "a/index.html"); !strings.Contains(got, "<li>Zed Extra</li>") {
+		t.Errorf("a/index.html does not list the new page:\n%s", got)
+	}
+}
+
+func TestRebuildRendersPagesThatListTheSite(t *testing.T) {
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nA\n",
+	})
+
+	b, out := testBuilder(source, "")
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/zed.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Zed Extra\n---\nZ\n")}
+	if _, err := b.Rebuild([]string{filepath.Join("source", "pages", "zed.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "a/index.html"); !strings.Contains(got, "<li>Zed Extra</li>") {
+		t.Errorf("a/index.html does not list the new page:\n%s", got)
+	}
+}
+
+func TestRebuildUpdatesRelated(t *testing.T) {
+	source := testSource(map[string]string{
+		"blog/one.md": "---\ntitle: One\ndate: 2024-01-01\ntags: [go]\n---\nOne\n",
+		"blog/two.md": "---\ntitle: Two\ndate: 2024-02-01\n---\nTwo\n",
+	})
+
+	b, out := testBuilder(source, "")
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/blog/three.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Three\ndate: 2024-03-01\ntags: [go]\n---\nThree\n")}
+	if _, err := b.Rebuild([]string{filepath.Join("source", "pages", "blog", "three.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "blog/one/index.html"); !strings.Contains(got, `<ul class="related"><li>Three</li><li>Two</li></ul>`) {
+		t.Errorf("blog/one/index.html does not list the new related page first:\n%s", got)
+	}
+}
+
+func TestRebuildUpdatesNeighbours(t *testing.T) {
+	source := testSource(map[string]string{
+		"blog/one.md": "---\ntitle: One\ndate: 2024-01-01\n---\nOne\n",
+		"blog/two.md": "---\ntitle: Two\ndate: 2024-02-01\n---\nTwo\n",
+	})
+
+	b, out := testBuilder(source, "")
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/blog/three.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Three\ndate: 2024-03-01\n---\nThree\n")}
+	if _, err := b.Rebuild([]string{filepath.Join("source", "pages", "blog", "three.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "blog/two/index.html"); !strings.Contains(got, "prev=Three next=One") {
+		t.Errorf("blog/two/index.html does not link to its new neighbour:\n%s", got)
+	}
+}
+
+// a home page listing the summaries and dates of the other pages
+const testHomeTemplate = `<!doctype html>
+<html><head></head><body>
+<ul>{{ range .Site.Pages }}<li>{{ .Summary }} {{ isoDate .Date }}</li>{{ end }}</ul>
+</body></html>`
+
+func testHomeSource() fstest.MapFS {
+	source := testSource(map[string]string{
+		"index.md":  "---\ntitle: Home\ntemplate: home\n---\nWelcome\n",
+		"blog/p.md": "---\ntitle: P\ndate: 2024-01-01\n---\nfirst summary\n",
+	})
+	source["templates/home.html"] = &fstest.MapFile{Data: []byte(testHomeTemplate)}
+	return source
+}
+
+func TestCachedPagesSeeChangedPages(t *testing.T) {
+	cacheDir := t.TempDir()
+	source := testHomeSource()
+
+	first, _ := testBuilder(source, cacheDir)
+	if err := first.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/blog/p.md"] = &fstest.MapFile{Data: []byte("---\ntitle: P\ndate: 2024-02-01\n---\nsecond summary\n")}
+
+	b, out := testBuilder(source, cacheDir)
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "index.html"); !strings.Contains(got, "<li>second summary 2024-02-01</li>") {
+		t.Errorf("index.html was served from the cache after blog/p.md changed:\n%s", got)
+	}
+}
+
+func TestRebuildRendersPagesThatReadChangedPages(t *testing.T) {
+	source := testHomeSource()
+
+	b, out := testBuilder(source, "")
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source["pages/blog/p.md"] = &fstest.MapFile{Data: []byte("---\ntitle: P\ndate: 2024-02-01\n---\nsecond summary\n")}
+	if _, err := b.Rebuild([]string{filepath.Join("source", "pages", "blog", "p.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readOutput(t, out, "index.html"); !strings.Contains(got, "<li>second summary 2024-02-01</li>") {
+		t.Errorf("index.html was not rendered again after blog/p.md changed:\n%s", got)
+	}
+}
+
+func TestCachedPagesReplayWarnings(t *testing.T) {
+	cacheDir := t.TempDir()
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nA\n",
+	})
+	delete(source, "scripts/default.js")
+
+	for _, strict := range []bool{false, false, true} {
+		var log bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Source = source
+		cfg.Output = NewMemoryOutput()
+		cfg.CacheDir = cacheDir
+		cfg.Strict = strict
+		cfg.Log = &log
+
+		err := New(cfg).Build(context.Background())
+		if strict != (err != nil) {
+			t.Errorf("strict %v: Build returned %v", strict, err)
+		}
+		if !strings.Contains(log.String(), "default script default.js does not exist") {
+			t.Errorf("strict %v: the missing script was not reported:\n%s", strict, log.String())
+		}
+	}
+
+	// a page with warnings is reused like any other
+	var log bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Source = source
+	cfg.Output = NewMemoryOutput()
+	cfg.CacheDir = cacheDir
+	cfg.Log = &log
+	if err := New(cfg).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(log.String(), "generated.") {
+		t.Errorf("a.md was not reused from the cache:\n%s", log.String())
+	}
+}
+
+func TestRebuildReportsWhetherItWrote(t *testing.T) {
+	source := testSource(map[string]string{
+		"a.md": "---\ntitle: A\n---\nA\n",
+	})
+
+	b, _ := testBuilder(source, "")
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		changed string
+		wrote   bool
+	}{
+		{filepath.Join("source", "pages", "4913"), false},
+		{filepath.Join("source", "pages", ".a.md.swp"), false},
+		{filepath.Join("source", "pages", "a.md"), true},
+	}
+	for _, test := range tests {
+		wrote, err := b.Rebuild([]string{test.changed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrote != test.wrote {
+			t.Errorf("Rebuild(%s) reported wrote = %v, want %v", test.changed, wrote, test.wrote)
+		}
+	}
+}
+
+func TestFeedsOfCachedPages(t *testing.T) {
+	cacheDir := t.TempDir()
+	source := testSource(map[string]string{
+		"blog/p.md": "---\ntitle: P\ndate: 2024-01-01\n---\nSee [the home page](/).\n",
+	})
+
+	for i := 0; i < 2; i++ {
+		cfg := DefaultConfig()
+		cfg.Source = source
+		cfg.CacheDir = cacheDir
+		cfg.BaseURL = "https://example.com"
+		cfg.Log = io.Discard
+		out := NewMemoryOutput()
+		cfg.Output = out
+		if err := New(cfg).Build(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := readOutput(t, out, "atom.xml"); !strings.Contains(got, "href=&#34;https://example.com/&#34;") {
+			t.Errorf("build %d: atom.xml does not carry the content of blog/p.md:\n%s", i+1, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// cacheVersion is part of every cache key; bump it whenever a change to goose
+// itself alters the HTML it renders for the same inputs.
+const cacheVersion = 1
+
+// buildCache lets repeated goose generate runs reuse the output of pages
+// whose inputs are unchanged. Each page is stored with the content hash of
+// every file it read and a hash of the settings that affect rendering.
+type buildCache struct {
+	dir      string
+	settings string
+	readFile func(path string) ([]byte, error)
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	previous cacheManifest
+	next     cacheManifest
+	hashes   map[string]string
+}
+
+type cacheManifest struct {
+	Version  int                   `json:"version"`
+	Settings string                `json:"settings"`
+	Pages    map[string]cacheEntry `json:"pages"`
+}
+
+type cacheEntry struct {
+	OutPath  string            `json:"outPath"`
+	Deps     map[string]string `json:"deps"`
+	Linked   []string          `json:"linked,omitempty"`
+	Site     string            `json:"site,omitempty"`
+	Warnings []cachedWarning   `json:"warnings,omitempty"`
+	Markdown string            `json:"markdown"`
+	Output   string            `json:"output"`
+}
+
+// cachedWarning is a warning of a cached page, replayed whenever the page
+// comes from the cache.
+type cachedWarning struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+}
+
+func (w cachedWarning) buildError(page string) *BuildError {
+	return &BuildError{Page: page, File: w.File, Line: w.Line, Stage: w.Stage, Err: errors.New(w.Message)}
+}
+
+// newBuildCache opens the cache in cfg.CacheDir. Hooks are keyed by name, as
+// their settings cannot be compared. With cfg.NoCache the previous manifest
+// is ignored, forcing every page to be rendered, but the cache is still
+// refreshed for the next run.
+func newBuildCache(cfg Config, hooks []string, readFile func(path string) ([]byte, error), logger *log.Logger) *buildCache {
+	dir, reuse := cfg.CacheDir, !cfg.NoCache
+
+	// none of these change what gets rendered
+	cfg.CacheDir, cfg.NoCache, cfg.Jobs, cfg.Strict, cfg.Log = "", false, 0, false, nil
+	cfg.Source, cfg.Output, cfg.Hooks = nil, nil, nil
+
+	c := &buildCache{
+		dir:      dir,
+		settings: hashString(fmt.Sprintf("%d %#v %q", cacheVersion, cfg, hooks)),
+		readFile: readFile,
+		logger:   logger,
+		hashes:   make(map[string]string),
+	}
+	c.next = cacheManifest{Version: cacheVersion, Settings: c.settings, Pages: make(map[string]cacheEntry)}
+
+	if !reuse {
+		return c
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logger.Printf("Warning: Could not read build cache: %v\n", err)
+		}
+		return c
+	}
+
+	if err := json.Unmarshal(manifest, &c.previous); err != nil {
+		c.logger.Printf("Warning: Ignoring corrupt build cache: %v\n", err)
+		c.previous = cacheManifest{}
+	}
+
+	return c
+}
+
+// lookup returns the cached entry for page if its settings, the pages it
+// links to (see linkedSources), the site fingerprint and every file it depends
+// on are unchanged, along with the cached output and the HTML rendered from
+// its Markdown.
+func (c *buildCache) lookup(page string, linked []string, site string) (cacheEntry, []byte, string, bool) {
+	c.mu.Lock()
+	entry, ok := c.previous.Pages[page]
+	fresh := ok && c.previous.Version == cacheVersion && c.previous.Settings == c.settings &&
+		slices.Equal(entry.Linked, linked) && entry.Site == site
+	c.mu.Unlock()
+
+	if !fresh || entry.Markdown == "" {
+		return cacheEntry{}, nil, "", false
+	}
+
+	for dep, hash := range entry.Deps {
+		if c.hashFile(dep) != hash {
+			return cacheEntry{}, nil, "", false
+		}
+	}
+
+	output, err := os.ReadFile(c.outputPath(entry.Output))
+	if err != nil {
+		return cacheEntry{}, nil, "", false
+	}
+	markdown, err := os.ReadFile(c.outputPath(entry.Markdown))
+	if err != nil {
+		return cacheEntry{}, nil, "", false
+	}
+
+	c.mu.Lock()
+	c.next.Pages[page] = entry
+	c.mu.Unlock()
+
+	return entry, output, string(markdown), true
+}
+
+// store records the result of rendering page.
+func (c *buildCache) store(page, outPath string, deps, linked []string, site string, warnings []*BuildError, markdown string, output []byte) {
+	entry := cacheEntry{OutPath: outPath, Deps: make(map[string]string), Linked: linked, Site: site}
+	for _, warning := range warnings {
+		entry.Warnings = append(entry.Warnings, cachedWarning{
+			File:    warning.File,
+			Line:    warning.Line,
+			Stage:   warning.Stage,
+			Message: warning.Err.Error(),
+		})
+	}
+	for _, dep := range deps {
+		entry.Deps[filepath.Clean(dep)] = c.hashFile(dep)
+	}
+
+	entry.Output = hashString(string(output))
+	entry.Markdown = hashString(markdown)
+	if err := os.MkdirAll(filepath.Join(c.dir, "pages"), 0755); err != nil {
+		c.logger.Printf("Warning: Could not create build cache: %v\n", err)
+		return
+	}
+	for hash, content := range map[string][]byte{entry.Output: output, entry.Markdown: []byte(markdown)} {
+		if err := os.WriteFile(c.outputPath(hash), content, 0644); err != nil {
+			c.logger.Printf("Warning: Could not write to build cache: %v\n", err)
+			return
+		}
+	}
+
+	c.mu.Lock()
+	c.next.Pages[page] = entry
+	c.mu.Unlock()
+}
+
+// save writes the manifest for this build and removes cached outputs that no
+// page refers to anymore.
+func (c *buildCache) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		c.logger.Printf("Warning: Could not create build cache: %v\n", err)
+		return
+	}
+
+	manifest, err := json.MarshalIndent(c.next, "", "  ")
+	if err != nil {
+		c.logger.Printf("Warning: Could not encode build cache: %v\n", err)
+		return
+	}
+
+	tmpPath := filepath.Join(c.dir, "manifest.json.tmp")
+	if err := os.WriteFile(tmpPath, manifest, 0644); err != nil {
+		c.logger.Printf("Warning: Could not write build cache: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmpPath, filepath.Join(c.dir, "manifest.json")); err != nil {
+		c.logger.Printf("Warning: Could not write build cache: %v\n", err)
+		return
+	}
+
+	used := make(map[string]bool)
+	for _, entry := range c.next.Pages {
+		used[entry.Output+".html"] = true
+		used[entry.Markdown+".html"] = true
+	}
+
+	files, err := os.ReadDir(filepath.Join(c.dir, "pages"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if !used[file.Name()] {
+			os.Remove(filepath.Join(c.dir, "pages", file.Name()))
+		}
+	}
+}
+
+func (c *buildCache) outputPath(hash string) string {
+	return filepath.Join(c.dir, "pages", hash+".html")
+}
+
+// hashFile returns the content hash of path, or "" if it cannot be read.
+// Hashes are memoized because stylesheets and templates are shared by most
+// pages.
+func (c *buildCache) hashFile(path string) string {
+	path = filepath.Clean(path)
+
+	c.mu.Lock()
+	hash, ok := c.hashes[path]
+	c.mu.Unlock()
+	if ok {
+		return hash
+	}
+
+	content, err := c.readFile(path)
+	if err == nil {
+		hash = hashString(string(content))
+	}
+
+	c.mu.Lock()
+	c.hashes[path] = hash
+	c.mu.Unlock()
+
+	return hash
+}
+
+// forgetHashes drops the memoized hashes, so that files are hashed again.
+func (c *buildCache) forgetHashes() {
+	c.mu.Lock()
+	c.hashes = make(map[string]string)
+	c.mu.Unlock()
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
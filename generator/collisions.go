@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PlannedOutput is a file a build is going to write, and the source file it
+// comes from.
+type PlannedOutput struct {
+	Name   string
+	Source string
+}
+
+// OutputPlanner is a SiteHook that writes files of its own. The files it
+// plans to write are checked for collisions along with every page and static
+// file before anything is written.
+type OutputPlanner interface {
+	Hook
+	PlannedOutputs(site *Site) []PlannedOutput
+}
+
+// plannedOutputs lists every file the build is going to write.
+func (b *Builder) plannedOutputs() []PlannedOutput {
+	var planned []PlannedOutput
+	for _, page := range b.site.allPages() {
+		planned = append(planned, PlannedOutput{Name: b.outputName(page.OutputPath), Source: page.SourcePath})
+	}
+
+	if exists, err := b.isDir(b.staticDir); exists && err == nil {
+		b.walkFiles(b.staticDir, func(path string) error {
+			relPath, err := filepath.Rel(b.staticDir, path)
+			if err == nil {
+				planned = append(planned, PlannedOutput{Name: filepath.ToSlash(filepath.Join("static", relPath)), Source: path})
+			}
+			return nil
+		})
+	}
+
+	for _, hook := range b.hooks {
+		if h, ok := hook.(OutputPlanner); ok {
+			planned = append(planned, h.PlannedOutputs(b.site)...)
+		}
+	}
+
+	return planned
+}
+
+// checkOutputs records an error for every output path that more than one
+// source would write. Paths that only differ in case count as the same path,
+// as they are on case-insensitive filesystems. It reports whether the build
+// is free of collisions.
+func (b *Builder) checkOutputs() bool {
+	bySlot := make(map[string][]PlannedOutput)
+	for _, output := range b.plannedOutputs() {
+		slot := strings.ToLower(path.Clean(output.Name))
+		bySlot[slot] = append(bySlot[slot], output)
+	}
+
+	var collisions []string
+	for slot, outputs := range bySlot {
+		if len(outputs) > 1 {
+			collisions = append(collisions, slot)
+		}
+	}
+	sort.Strings(collisions)
+
+	for _, slot := range collisions {
+		outputs := bySlot[slot]
+		first := outputs[0]
+
+		var others []string
+		for _, output := range outputs[1:] {
+			other := output.Source
+			if output.Name != first.Name {
+				other += fmt.Sprintf(" (as %s)", output.Name)
+			}
+			others = append(others, other)
+		}
+
+		b.report.fail(first.Source, newBuildError(first.Source, stageWrite, first.Source,
+			fmt.Errorf("output %s is also written by %s", first.Name, strings.Join(others, ", "))))
+	}
+
+	return len(collisions) == 0
+}
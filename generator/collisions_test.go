@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckOutputs(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]string
+		want  string // "" when nothing collides
+	}{
+		{
+			"distinct",
+			map[string]string{"a.md": "A", "b.md": "B"},
+			"",
+		},
+		{
+			"static file",
+			map[string]string{"a.md": "---\nurl: /static/logo.svg\n---\nA"},
+			"output static/logo.svg is also written by",
+		},
+		{
+			"url front matter",
+			map[string]string{"a.md": "A", "b.md": "---\nurl: /a/\n---\nB"},
+			"output a/index.html is also written by",
+		},
+		{
+			"case",
+			map[string]string{"a.md": "A", "b.md": "---\nurl: /A/\n---\nB"},
+			"(as A/index.html)",
+		},
+	}
+	for _, test := range tests {
+		source := testSource(test.pages)
+		source["static/logo.svg"] = &fstest.MapFile{Data: []byte("<svg></svg>")}
+
+		b, _ := testBuilder(source, "")
+		if err := b.Load(context.Background()); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		ok := b.checkOutputs()
+		if ok != (test.want == "") {
+			t.Errorf("%s: checkOutputs() = %v", test.name, ok)
+		}
+		if test.want != "" && (len(b.report.errors) != 1 || !strings.Contains(b.report.errors[0].Error(), test.want)) {
+			t.Errorf("%s: got errors %v, want one containing %q", test.name, b.report.errors, test.want)
+		}
+	}
+}
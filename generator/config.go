@@ -0,0 +1,196 @@
+package generator
+
+import (
+	"io"
+	"io/fs"
+	"runtime"
+)
+
+// Config holds every setting that affects a build. The directories for pages,
+// styles, scripts, templates and static files are relative to SourceDir.
+type Config struct {
+	SourceDir    string
+	BuildDir     string
+	PagesDir     string
+	StylesDir    string
+	ScriptsDir   string
+	TemplatesDir string
+	StaticDir    string
+
+	DefaultTemplate string
+	DefaultStyles   []string
+	DefaultScripts  []string
+	DefaultMetadata map[string]interface{}
+
+	// Environment names the environment being built for, e.g. "production".
+	// Templates see it as {{ .Environment }}, and pages as the environment
+	// front matter value unless they set their own.
+	Environment string
+
+	SyntaxHighlightingStyle               string
+	SyntaxHighlightingUseCustomBackground bool
+	SyntaxHighlightingCustomBackground    string
+	EnableCodeBlockLineNumbers            bool
+	EnableEmoji                           bool
+
+	// TOCMinLevel and TOCMaxLevel are the heading levels that make up the
+	// table of contents of a page.
+	TOCMinLevel int
+	TOCMaxLevel int
+
+	// SummaryLength is the number of words in the summary of a page without
+	// a summary front matter value or a <!--more--> marker, and
+	// WordsPerMinute the reading speed its reading time is based on.
+	SummaryLength  int
+	WordsPerMinute int
+
+	MinifyOutput  bool
+	EnableHtmx    bool
+	AddHxBoost    bool
+	HtmxSourceURL string
+	IncludeDrafts bool
+	PrettyURLs    bool
+
+	// Timezone is the IANA name of the time zone of front matter dates that
+	// do not have one, e.g. "Europe/Berlin"; empty means UTC.
+	Timezone string
+
+	// BuildFuture includes pages whose publishDate (or date) has not come
+	// yet, and BuildExpired pages whose expiryDate has passed.
+	BuildFuture  bool
+	BuildExpired bool
+
+	// Permalinks maps a section, the first directory under PagesDir, to the
+	// URL pattern of its pages, e.g. "/blog/:year/:month/:slug/". Patterns
+	// can use :year, :month, :day, :slug, :section, :filename and :dir.
+	Permalinks map[string]string
+
+	// BaseURL is the address the site is published at, e.g.
+	// "https://example.com". Feeds are only generated when it is set: an RSS
+	// and an Atom feed for the whole site, and for every section and term.
+	// FeedLimit caps the number of items in a feed; 0 means no limit.
+	BaseURL   string
+	FeedLimit int
+
+	// Sitemap writes a sitemap.xml listing every page, and RobotsTxt a
+	// robots.txt pointing at it, when BaseURL is set. RobotsDisallow lists
+	// the paths robots.txt disallows, e.g. "/" to keep a staging site out of
+	// search engines.
+	Sitemap        bool
+	RobotsTxt      bool
+	RobotsDisallow []string
+
+	// RedirectsFile writes every alias to a _redirects file as well, and
+	// NginxRedirectMap to an nginx map snippet in nginx-redirects.conf.
+	RedirectsFile    bool
+	NginxRedirectMap bool
+
+	// Taxonomies are the front matter keys pages are grouped by, e.g. tags.
+	// Each one gets an index page listing its terms, e.g. /tags/, and a page
+	// per term listing its pages, e.g. /tags/go/.
+	Taxonomies []string
+
+	// RelatedCount is the number of related pages every page gets; 0 turns
+	// them off. RelatedWeights is how much each shared term of a taxonomy,
+	// e.g. "tags", a shared "section" and each shared "keywords" front
+	// matter value count towards a page being related to another.
+	RelatedCount   int
+	RelatedWeights map[string]float64
+
+	// SectionSort is how section pages sort the pages they list: "date",
+	// "publishDate" or "lastmod" (newest first), "weight" or "title". A
+	// section page can override it with the sort front matter.
+	SectionSort string
+
+	// Paginate splits section and term pages into pages of this many items,
+	// e.g. /blog/, /blog/page/2/ and so on; 0 turns pagination off. A section
+	// page can override it with the paginate front matter.
+	Paginate int
+
+	// PaginateFragments also writes the items of every page after the first
+	// to e.g. /blog/page/2/fragment.html, and adds an element that loads them
+	// with htmx as it scrolls into view to the element of the list template
+	// marked with data-goose-paginate, for infinite scrolling.
+	PaginateFragments bool
+
+	// CacheDir is where rendered pages are kept between builds; the cache is
+	// off while it is empty. NoCache ignores what is already cached.
+	CacheDir string
+	NoCache  bool
+
+	// Jobs is the number of pages rendered in parallel.
+	Jobs int
+
+	// Strict turns warnings into errors.
+	Strict bool
+
+	// LiveReloadScript is added to the <head> of every page when set. It is
+	// used by goose serve and should stay empty for production builds.
+	LiveReloadScript string
+
+	// Source is read instead of the SourceDir directory when set, e.g. an
+	// embed.FS or fstest.MapFS holding the pages, styles, scripts, templates
+	// and static directories. SourceDir is still used to name files in logs
+	// and errors.
+	Source fs.FS
+
+	// Output receives the built site; nil means a DirOutput for BuildDir.
+	Output Output
+
+	// Hooks run custom logic at stages of the build, after the built-in
+	// hooks for htmx, hx-boost and live reload; see Hook.
+	Hooks []Hook
+
+	// Log receives progress and per-page log lines; nil means os.Stdout.
+	Log io.Writer
+}
+
+// DefaultConfig returns the configuration goose uses when nothing is set.
+func DefaultConfig() Config {
+	return Config{
+		SourceDir:    "source",
+		BuildDir:     "build",
+		PagesDir:     "pages",
+		StylesDir:    "styles",
+		ScriptsDir:   "scripts",
+		TemplatesDir: "templates",
+		StaticDir:    "static",
+
+		DefaultTemplate: "default.html",
+		DefaultStyles:   []string{"default.css"},
+		DefaultScripts:  []string{"default.js"},
+		DefaultMetadata: map[string]interface{}{},
+		Environment:     "production",
+
+		SyntaxHighlightingStyle:    "github",
+		EnableCodeBlockLineNumbers: true,
+		EnableEmoji:                true,
+
+		TOCMinLevel: 2,
+		TOCMaxLevel: 3,
+
+		SummaryLength:  70,
+		WordsPerMinute: 200,
+
+		MinifyOutput:  true,
+		EnableHtmx:    true,
+		AddHxBoost:    true,
+		HtmxSourceURL: "https://unpkg.com/htmx.org@2.0.4",
+		PrettyURLs:    true,
+
+		Taxonomies:   []string{"tags", "categories"},
+		SectionSort:  "date",
+		RelatedCount: 5,
+		RelatedWeights: map[string]float64{
+			"tags":       1,
+			"categories": 1,
+			"keywords":   1,
+			"section":    0.5,
+		},
+		FeedLimit: 20,
+		Sitemap:   true,
+		RobotsTxt: true,
+
+		Jobs: runtime.NumCPU(),
+	}
+}
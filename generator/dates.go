@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// frontMatterDate returns the date under key in a page's front matter. Dates
+// without a time zone are in Config.Timezone.
+func (b *Builder) frontMatterDate(metadata map[string]interface{}, key string) (time.Time, bool, error) {
+	switch date := metadata[key].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return date, true, nil
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, date, b.location); err == nil {
+				return t, true, nil
+			}
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%s %v is not a date like 2006-01-02 or 2006-01-02T15:04:05", key, metadata[key])
+}
+
+// pageDates works out the dates of a page: date and publishDate stand in for
+// each other, and lastmod falls back to the date.
+func (b *Builder) pageDates(metadata map[string]interface{}) (date, publishDate, lastmod, expiryDate time.Time, err error) {
+	dates := make(map[string]time.Time, 4)
+	for _, key := range []string{"date", "publishDate", "lastmod", "expiryDate"} {
+		t, _, err := b.frontMatterDate(metadata, key)
+		if err != nil {
+			return date, publishDate, lastmod, expiryDate, err
+		}
+		dates[key] = t
+	}
+
+	date, publishDate = dates["date"], dates["publishDate"]
+	if date.IsZero() {
+		date = publishDate
+	}
+	if publishDate.IsZero() {
+		publishDate = date
+	}
+
+	lastmod = dates["lastmod"]
+	if lastmod.IsZero() {
+		lastmod = date
+	}
+
+	return date, publishDate, lastmod, dates["expiryDate"], nil
+}
+
+// unpublished tells why a page is not part of the build yet or anymore, or
+// returns "" when it is.
+func (b *Builder) unpublished(publishDate, expiryDate, now time.Time) string {
+	if !b.config.BuildFuture && publishDate.After(now) {
+		return fmt.Sprintf("it is not published until %s", publishDate.Format(time.RFC3339))
+	}
+	if !b.config.BuildExpired && !expiryDate.IsZero() && !expiryDate.After(now) {
+		return fmt.Sprintf("it expired on %s", expiryDate.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// templateFuncs are the functions templates can use besides the built-in
+// ones, for formatting dates in Config.Timezone:
+//
+//	{{ .Page.Date | date "January 2, 2006" }}
+//	{{ isoDate .Page.Lastmod }}
+func (b *Builder) templateFuncs() template.FuncMap {
+	format := func(layout string, t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(b.location).Format(layout)
+	}
+
+	return template.FuncMap{
+		"date":    format,
+		"isoDate": func(t time.Time) string { return format("2006-01-02", t) },
+		"rfc3339": func(t time.Time) string { return format(time.RFC3339, t) },
+		"now":     time.Now,
+	}
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPageDates(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Timezone = "America/New_York"
+	cfg.Log = io.Discard
+	b := New(cfg)
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, newYork) }
+
+	tests := []struct {
+		name                                   string
+		metadata                               map[string]interface{}
+		date, publishDate, lastmod, expiryDate time.Time
+		wantErr                                bool
+	}{
+		{"none", map[string]interface{}{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}, false},
+		{"date", map[string]interface{}{"date": "2024-01-02"}, day(2), day(2), day(2), time.Time{}, false},
+		{"publishDate", map[string]interface{}{"publishDate": "2024-01-03"}, day(3), day(3), day(3), time.Time{}, false},
+		{
+			"all",
+			map[string]interface{}{"date": "2024-01-02", "publishDate": "2024-01-03", "lastmod": "2024-01-04", "expiryDate": "2024-01-05"},
+			day(2), day(3), day(4), day(5), false,
+		},
+		{"with a time", map[string]interface{}{"date": "2024-01-02 00:00:00"}, day(2), day(2), day(2), time.Time{}, false},
+		{"with a time zone", map[string]interface{}{"date": "2024-01-02T05:00:00Z"}, day(2), day(2), day(2), time.Time{}, false},
+		{"not a date", map[string]interface{}{"date": "soon"}, time.Time{}, time.Time{}, time.Time{}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		date, publishDate, lastmod, expiryDate, err := b.pageDates(test.metadata)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: pageDates returned error %v", test.name, err)
+			continue
+		}
+
+		got := []time.Time{date, publishDate, lastmod, expiryDate}
+		want := []time.Time{test.date, test.publishDate, test.lastmod, test.expiryDate}
+		for i := range got {
+			if !got[i].Equal(want[i]) {
+				t.Errorf("%s: pageDates = %v, want %v", test.name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestUnpublished(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name                string
+		publishDate, expiry time.Time
+		future, expired     bool
+		want                bool
+	}{
+		{"published", past, time.Time{}, false, false, false},
+		{"future", future, time.Time{}, false, false, true},
+		{"future with BuildFuture", future, time.Time{}, true, false, false},
+		{"expired", past, past, false, false, true},
+		{"expiring now", past, now, false, false, true},
+		{"expired with BuildExpired", past, past, false, true, false},
+		{"not expired yet", past, future, false, false, false},
+	}
+	for _, test := range tests {
+		cfg := DefaultConfig()
+		cfg.BuildFuture = test.future
+		cfg.BuildExpired = test.expired
+		cfg.Log = io.Discard
+		b := New(cfg)
+
+		if reason := b.unpublished(test.publishDate, test.expiry, now); (reason != "") != test.want {
+			t.Errorf("%s: unpublished = %q", test.name, reason)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// depGraph records, for every page in pagesDir, the output it produced and
+// every file it read while rendering: its Markdown, templates, stylesheets,
+// scripts and the pages reached through {{ from ... }} placeholders.
+type depGraph struct {
+	mu    sync.Mutex
+	pages map[string]pageDeps
+}
+
+type pageDeps struct {
+	outPath string
+	deps    []string
+}
+
+func newDepGraph() *depGraph {
+	return &depGraph{pages: make(map[string]pageDeps)}
+}
+
+func (d *depGraph) record(page, outPath string, deps []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cleaned := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		cleaned = append(cleaned, filepath.Clean(dep))
+	}
+
+	d.pages[filepath.Clean(page)] = pageDeps{outPath: outPath, deps: cleaned}
+}
+
+// forget drops page from the graph and returns the output it last produced.
+func (d *depGraph) forget(page string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	page = filepath.Clean(page)
+	previous := d.pages[page]
+	delete(d.pages, page)
+	return previous.outPath
+}
+
+// dependents returns every page that read path, or a file inside path when it
+// is a directory, sorted so rebuilds happen in a stable order.
+func (d *depGraph) dependents(path string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path = filepath.Clean(path)
+
+	var pages []string
+	for page, entry := range d.pages {
+		for _, dep := range entry.deps {
+			if dep == path || helpers.IsWithin(dep, path) {
+				pages = append(pages, page)
+				break
+			}
+		}
+	}
+
+	sort.Strings(pages)
+	return pages
+}
@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// stages of the pipeline a BuildError can come from
+const (
+	stageFrontMatter = "front matter"
+	stageMarkdown    = "markdown"
+	stageTemplate    = "template"
+	stageAssets      = "assets"
+	stageMinify      = "minify"
+	stageWrite       = "write"
+	stageSite        = "site"
+)
+
+// BuildError is a problem found while building a page. File is the file the
+// problem is in, which is not always the page itself, e.g. for a broken
+// template, and Line is 0 when it is not known.
+type BuildError struct {
+	Page  string
+	File  string
+	Line  int
+	Stage string
+	Err   error
+}
+
+func (e *BuildError) Error() string {
+	location := e.File
+	if location == "" {
+		location = e.Page
+	}
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+
+	// template errors name the stage, template and line themselves
+	detail := templateErrorPrefixRe.ReplaceAllString(e.Err.Error(), "")
+
+	msg := fmt.Sprintf("%s: %s: %s", location, e.Stage, detail)
+	if e.Page != "" && e.File != "" && e.Page != e.File {
+		msg += fmt.Sprintf(" (while building %s)", e.Page)
+	}
+	return msg
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+func newBuildError(page, stage, file string, err error) *BuildError {
+	return &BuildError{Page: page, File: file, Line: errorLine(err), Stage: stage, Err: err}
+}
+
+var (
+	templateErrorPrefixRe = regexp.MustCompile(`^template: [^:]*(:\d+)*: `)
+	templateLineRe        = regexp.MustCompile(`^template: [^:]*:(\d+)`)
+	yamlLineRe            = regexp.MustCompile(`^yaml: line (\d+)`)
+)
+
+// errorLine digs a line number out of template and front matter errors.
+func errorLine(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if match := templateLineRe.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+
+	if match := yamlLineRe.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line + 1 // front matter starts after the opening ---
+	}
+
+	return 0
+}
+
+// buildReport collects every error and warning of a build, so that one bad
+// page does not stop the others from being built.
+type buildReport struct {
+	strict bool
+
+	mu       sync.Mutex
+	errors   []*BuildError
+	warnings []*BuildError
+}
+
+func newBuildReport(strict bool) *buildReport {
+	return &buildReport{strict: strict}
+}
+
+// fail records err as an error of page.
+func (r *buildReport) fail(page string, err error) {
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		buildErr = &BuildError{Page: page, Stage: "render", Err: err}
+	}
+
+	r.mu.Lock()
+	r.errors = append(r.errors, buildErr)
+	r.mu.Unlock()
+}
+
+// warn logs a warning and records it; in strict mode it fails the build.
+func (r *buildReport) warn(logger *log.Logger, warning *BuildError) {
+	logger.Printf("Warning: %v\n", warning)
+
+	r.mu.Lock()
+	r.warnings = append(r.warnings, warning)
+	r.mu.Unlock()
+}
+
+func (r *buildReport) failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.errors) > 0 || r.strict && len(r.warnings) > 0
+}
+
+// print writes a summary of every error and warning to w.
+func (r *buildReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.errors) == 0 && len(r.warnings) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%d error(s), %d warning(s):\n", len(r.errors), len(r.warnings))
+	for _, err := range r.errors {
+		fmt.Fprintf(w, "  error: %v\n", err)
+	}
+	for _, warning := range r.warnings {
+		if r.strict {
+			fmt.Fprintf(w, "  error (strict): %v\n", warning)
+		} else {
+			fmt.Fprintf(w, "  warning: %v\n", warning)
+		}
+	}
+}
+
+// err returns the errors of a failed build as BuildErrors, or nil.
+func (r *buildReport) err() error {
+	if !r.failed() {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := append(BuildErrors{}, r.errors...)
+	if r.strict {
+		errs = append(errs, r.warnings...)
+	}
+	return errs
+}
+
+// BuildErrors is returned when a build fails. It holds every error of the
+// build and, in strict mode, every warning.
+type BuildErrors []*BuildError
+
+func (e BuildErrors) Error() string {
+	return fmt.Sprintf("%d error(s)", len(e))
+}
@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *BuildError
+		want string
+	}{
+		{
+			newBuildError("pages/a.md", stageFrontMatter, "pages/a.md", errors.New("yaml: line 1: did not find expected key")),
+			"pages/a.md:2: front matter: yaml: line 1: did not find expected key",
+		},
+		{
+			newBuildError("pages/a.md", stageTemplate, "templates/default.html", errors.New(`template: templates/default.html:3: unexpected "<" in operand`)),
+			`templates/default.html:3: template: unexpected "<" in operand (while building pages/a.md)`,
+		},
+		{
+			newBuildError("pages/a.md", stageTemplate, "templates/default.html", errors.New(`template: :4:89: executing "" at <.X>: nil`)),
+			`templates/default.html:4: template: executing "" at <.X>: nil (while building pages/a.md)`,
+		},
+		{
+			newBuildError("pages/a.md", stageAssets, "styles/a.css", errors.New("stylesheet a.css does not exist")),
+			"styles/a.css: assets: stylesheet a.css does not exist (while building pages/a.md)",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package generator
+
+import "errors"
+
+// exchange atomically swaps the directories at a and b, which is only
+// supported on Linux.
+func exchange(a, b string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,322 @@
+package generator
+
+import (
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// names of the feeds of a list, e.g. /blog/index.xml and /blog/atom.xml
+const (
+	rssFile  = "index.xml"
+	atomFile = "atom.xml"
+)
+
+var rootRelativeRe = regexp.MustCompile(`(href|src)="/`)
+
+// feeds writes an RSS 2.0 and an Atom feed for the whole site and for every
+// section and term page, with the HTML rendered from the Markdown of each
+// page as its content.
+type feeds struct {
+	baseURL string
+	limit   int
+}
+
+// feed is a list of pages with a feed of its own.
+type feed struct {
+	title       string
+	description string
+	url         string // of the list the feed is for
+	base        string // of the feed files, without the file name
+	source      string
+	pages       []*Page
+}
+
+// feedsHook returns the feeds hook for the config of b.
+func (b *Builder) feedsHook() feeds {
+	return feeds{
+		baseURL: b.baseURL,
+		limit:   b.config.FeedLimit,
+	}
+}
+
+func (feeds) Name() string { return "feeds" }
+
+func (h feeds) PlannedOutputs(site *Site) []PlannedOutput {
+	var planned []PlannedOutput
+	for _, f := range h.feeds(site) {
+		planned = append(planned,
+			PlannedOutput{Name: urlFile(f.base + "/" + rssFile), Source: f.source},
+			PlannedOutput{Name: urlFile(f.base + "/" + atomFile), Source: f.source},
+		)
+	}
+	return planned
+}
+
+func (h feeds) AfterSite(site *Site, out FileWriter) error {
+	// pages show up in several feeds, but only need their links made
+	// absolute once
+	rendered := make(map[*Page]string)
+	content := func(page *Page) string {
+		html, ok := rendered[page]
+		if !ok {
+			html = rootRelativeRe.ReplaceAllString(page.html, `$1="`+h.baseURL+`/`)
+			rendered[page] = html
+		}
+		return html
+	}
+
+	var errs []error
+	for _, f := range h.feeds(site) {
+		items := f.pages
+		if h.limit > 0 && len(items) > h.limit {
+			items = items[:h.limit]
+		}
+
+		rss := rssFeed{
+			Version: "2.0",
+			Atom:    "http://www.w3.org/2005/Atom",
+			Channel: rssChannel{
+				Title:       f.title,
+				Link:        h.baseURL + f.url,
+				Description: f.description,
+				Self:        rssAtomLink{Href: h.baseURL + f.base + "/" + rssFile, Rel: "self", Type: "application/rss+xml"},
+			},
+		}
+		atom := atomFeed{
+			Xmlns: "http://www.w3.org/2005/Atom",
+			ID:    h.baseURL + f.url,
+			Title: f.title,
+			Links: []atomLink{
+				{Href: h.baseURL + f.url},
+				{Href: h.baseURL + f.base + "/" + atomFile, Rel: "self"},
+			},
+		}
+
+		var updated time.Time
+		for _, page := range items {
+			html := content(page)
+
+			link := h.baseURL + page.URL
+			description, _ := page.Meta["description"].(string)
+			if description == "" {
+				description = page.Summary
+			}
+			author, _ := page.Meta["author"].(string)
+
+			item := rssItem{
+				Title:       page.Title,
+				Link:        link,
+				GUID:        link,
+				Author:      author,
+				Description: html,
+			}
+			entry := atomEntry{
+				ID:      link,
+				Title:   page.Title,
+				Links:   []atomLink{{Href: link}},
+				Summary: description,
+				Content: atomContent{Type: "html", Body: html},
+			}
+
+			if !page.Date.IsZero() {
+				item.PubDate = page.Date.Format(time.RFC1123Z)
+				entry.Updated = page.Date.Format(time.RFC3339)
+				entry.Published = entry.Updated
+				if page.Date.After(updated) {
+					updated = page.Date
+				}
+			}
+			if author != "" {
+				entry.Author = &atomAuthor{Name: author}
+			}
+
+			rss.Channel.Items = append(rss.Channel.Items, item)
+			atom.Entries = append(atom.Entries, entry)
+		}
+
+		atom.Updated = updated.Format(time.RFC3339)
+		for i := range atom.Entries {
+			if atom.Entries[i].Updated == "" {
+				atom.Entries[i].Updated = atom.Updated
+			}
+		}
+		if !updated.IsZero() {
+			rss.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+		}
+
+		for _, doc := range []struct {
+			name string
+			feed interface{}
+		}{{rssFile, rss}, {atomFile, atom}} {
+			name := urlFile(f.base + "/" + doc.name)
+			output, err := xml.MarshalIndent(doc.feed, "", "  ")
+			if err == nil {
+				err = out.WriteFile(name, append([]byte(xml.Header), output...))
+			}
+			if err != nil {
+				errs = append(errs, newBuildError(f.source, stageWrite, name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// feeds lists the feeds of site: one for the whole site, and one for every
+// section and term.
+func (h feeds) feeds(site *Site) []feed {
+	var pages []*Page
+	var home *Page
+	for _, page := range site.Pages {
+		if page.URL == "/" || page.URL == "/index.html" {
+			home = page
+		} else if page.Kind == KindPage {
+			pages = append(pages, page)
+		}
+	}
+
+	title := h.baseURL
+	var description string
+	if home != nil {
+		title = home.Title
+		description, _ = home.Meta["description"].(string)
+	}
+
+	feeds := []feed{{
+		title:       title,
+		description: description,
+		url:         "/",
+		source:      "the baseURL setting",
+		pages:       newestFirst(pages),
+	}}
+
+	for _, page := range site.allPages() {
+		if page.Kind != KindSection && page.Kind != KindTerm {
+			continue
+		}
+		if page.Paginator != nil && page.Paginator.PageNumber > 1 {
+			continue // a later page of a paginated list
+		}
+
+		description, _ := page.Meta["description"].(string)
+		feeds = append(feeds, feed{
+			title:       page.Title,
+			description: description,
+			url:         page.URL,
+			base:        listBase(page.URL),
+			source:      page.SourcePath,
+			pages:       newestFirst(page.Pages),
+		})
+	}
+
+	return feeds
+}
+
+// feedLinks returns the <link rel="alternate"> elements of a page: the feeds
+// of the site, and those of the list when the page is a section or term page.
+func (h feeds) feedLinks(page *Page) [][2]string {
+	bases := []string{""}
+	if page.Kind == KindSection || page.Kind == KindTerm {
+		url := page.URL
+		if page.Paginator != nil {
+			url = page.Paginator.First
+		}
+		bases = append(bases, listBase(url))
+	}
+
+	var links [][2]string
+	for _, base := range bases {
+		links = append(links,
+			[2]string{"application/rss+xml", h.baseURL + base + "/" + rssFile},
+			[2]string{"application/atom+xml", h.baseURL + base + "/" + atomFile},
+		)
+	}
+	return links
+}
+
+// listBase returns the URL a list's extra files go under, e.g. /blog for
+// /blog/ and /blog.html.
+func listBase(url string) string {
+	url = strings.TrimSuffix(url, "index.html")
+	url = strings.TrimSuffix(url, "/")
+	return strings.TrimSuffix(url, ".html")
+}
+
+// newestFirst returns a copy of pages sorted by date, newest first.
+func newestFirst(pages []*Page) []*Page {
+	sorted := append([]*Page{}, pages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.After(sorted[j].Date)
+	})
+	return sorted
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Self          rssAtomLink `xml:"atom:link"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	Description string `xml:"description"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   string      `xml:"summary,omitempty"`
+	Content   atomContent `xml:"content"`
+}
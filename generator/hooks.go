@@ -0,0 +1,277 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// Hook is a plugin that runs custom logic during a build. A hook implements
+// one or more of the stage interfaces below, and is only called for the
+// stages it implements. Hooks run in the order they are registered in
+// Config.Hooks, after the built-in ones.
+//
+// Hooks are called concurrently for different pages, so they must be safe for
+// concurrent use. Rendered pages are cached by the name of every hook, so put
+// a version in the name of a hook whose output changes.
+type Hook interface {
+	Name() string
+}
+
+// FrontMatterHook runs once a page's front matter is extracted, before its
+// title, dates, URL and summary are worked out from it or the page is skipped
+// as a draft. Only page.SourcePath, page.Meta and page.Content are set yet,
+// and it may change page.Meta.
+type FrontMatterHook interface {
+	Hook
+	AfterFrontMatter(page *Page) error
+}
+
+// MarkdownHook runs on the HTML rendered from a page's Markdown, before it is
+// put into the template.
+type MarkdownHook interface {
+	Hook
+	AfterMarkdown(page *Page, content string) (string, error)
+}
+
+// DocumentHook runs on the parsed template of a page, after goose has added
+// the title, styles and scripts to its <head>, and before the template is
+// executed. Template actions are replaced by placeholders in doc, and put
+// back afterwards.
+type DocumentHook interface {
+	Hook
+	OnDocument(page *Page, doc *html.Node) error
+}
+
+// TemplateHook runs on the output of the executed template, before it is
+// minified.
+type TemplateHook interface {
+	Hook
+	AfterTemplate(page *Page, output []byte) ([]byte, error)
+}
+
+// WriteHook runs on the final output of a page, right before it is written.
+type WriteHook interface {
+	Hook
+	BeforeWrite(page *Page, output []byte) ([]byte, error)
+}
+
+// SiteHook runs once every page has been built, and again after every
+// rebuild. Files it writes to out become part of the site.
+type SiteHook interface {
+	Hook
+	AfterSite(site *Site, out FileWriter) error
+}
+
+// builtinHooks returns the hooks goose itself registers for its config.
+func (b *Builder) builtinHooks() []Hook {
+	config := b.config
+
+	var hooks []Hook
+	if config.EnableHtmx {
+		hooks = append(hooks, htmxScript{src: config.HtmxSourceURL})
+	}
+	if config.LiveReloadScript != "" {
+		hooks = append(hooks, liveReload{script: config.LiveReloadScript})
+	}
+	if config.AddHxBoost {
+		hooks = append(hooks, hxBoost{})
+	}
+	hooks = append(hooks, aliases{
+		redirectsFile: config.RedirectsFile,
+		nginxMap:      config.NginxRedirectMap,
+	})
+	if b.baseURL != "" {
+		hooks = append(hooks, b.feedsHook())
+	}
+	if b.baseURL != "" && (config.Sitemap || config.RobotsTxt) {
+		hooks = append(hooks, sitemap{
+			baseURL:  b.baseURL,
+			sitemap:  config.Sitemap,
+			robots:   config.RobotsTxt,
+			disallow: config.RobotsDisallow,
+		})
+	}
+	return hooks
+}
+
+// hookError names the hook an error came from.
+func hookError(hook Hook, err error) error {
+	return fmt.Errorf("%s: %w", hook.Name(), err)
+}
+
+func (b *Builder) afterFrontMatter(page *Page) error {
+	for _, hook := range b.hooks {
+		if h, ok := hook.(FrontMatterHook); ok {
+			if err := h.AfterFrontMatter(page); err != nil {
+				return hookError(hook, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Builder) afterMarkdown(page *Page, content string) (string, error) {
+	for _, hook := range b.hooks {
+		if h, ok := hook.(MarkdownHook); ok {
+			var err error
+			if content, err = h.AfterMarkdown(page, content); err != nil {
+				return "", hookError(hook, err)
+			}
+		}
+	}
+	return content, nil
+}
+
+func (b *Builder) onDocument(page *Page, doc *html.Node) error {
+	for _, hook := range b.hooks {
+		if h, ok := hook.(DocumentHook); ok {
+			if err := h.OnDocument(page, doc); err != nil {
+				return hookError(hook, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Builder) afterTemplate(page *Page, output []byte) ([]byte, error) {
+	for _, hook := range b.hooks {
+		if h, ok := hook.(TemplateHook); ok {
+			var err error
+			if output, err = h.AfterTemplate(page, output); err != nil {
+				return nil, hookError(hook, err)
+			}
+		}
+	}
+	return output, nil
+}
+
+func (b *Builder) beforeWrite(page *Page, output []byte) ([]byte, error) {
+	for _, hook := range b.hooks {
+		if h, ok := hook.(WriteHook); ok {
+			var err error
+			if output, err = h.BeforeWrite(page, output); err != nil {
+				return nil, hookError(hook, err)
+			}
+		}
+	}
+	return output, nil
+}
+
+// afterSite runs every SiteHook, recording failures in the build report. A
+// hook can report several problems at once with errors.Join, and point at
+// the page they are about by returning a *BuildError.
+func (b *Builder) afterSite(out FileWriter) {
+	for _, hook := range b.hooks {
+		h, ok := hook.(SiteHook)
+		if !ok {
+			continue
+		}
+
+		err := h.AfterSite(b.site, out)
+		if err == nil {
+			continue
+		}
+
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, err := range errs {
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) {
+				err = newBuildError("", stageSite, "", hookError(hook, err))
+			}
+			b.report.fail("", err)
+		}
+	}
+}
+
+// hookNames lists the hooks that are part of the cache key.
+func hookNames(hooks []Hook) []string {
+	names := make([]string, len(hooks))
+	for i, hook := range hooks {
+		names[i] = hook.Name()
+	}
+	return names
+}
+
+// walkElements calls fn for every element node under n.
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		walkElements(c, fn)
+		c = next
+	}
+}
+
+// appendToHead appends node to the <head> of doc.
+func appendToHead(doc *html.Node, node *html.Node) {
+	walkElements(doc, func(n *html.Node) {
+		if n.Data == "head" && node.Parent == nil {
+			n.AppendChild(node)
+		}
+	})
+}
+
+// htmxScript adds the htmx script to the <head> of every page.
+type htmxScript struct {
+	src string
+}
+
+func (htmxScript) Name() string { return "htmx" }
+
+func (h htmxScript) OnDocument(page *Page, doc *html.Node) error {
+	appendToHead(doc, &html.Node{
+		Type: html.ElementNode,
+		Data: "script",
+		Attr: []html.Attribute{
+			{
+				Key: "src",
+				Val: h.src,
+			},
+		},
+	})
+	return nil
+}
+
+// liveReload adds the live reload client of goose serve to every page.
+type liveReload struct {
+	script string
+}
+
+func (liveReload) Name() string { return "live-reload" }
+
+func (h liveReload) OnDocument(page *Page, doc *html.Node) error {
+	appendToHead(doc, &html.Node{
+		Type: html.ElementNode,
+		Data: "script",
+		FirstChild: &html.Node{
+			Type: html.TextNode,
+			Data: h.script,
+		},
+	})
+	return nil
+}
+
+// hxBoost adds hx-boost="true" to every link, so htmx swaps pages in place.
+type hxBoost struct{}
+
+func (hxBoost) Name() string { return "hx-boost" }
+
+func (hxBoost) OnDocument(page *Page, doc *html.Node) error {
+	walkElements(doc, func(n *html.Node) {
+		if n.Data == "a" {
+			n.Attr = append(n.Attr, html.Attribute{
+				Key: "hx-boost",
+				Val: "true",
+			})
+		}
+	})
+	return nil
+}
@@ -0,0 +1,232 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// Output is where a build writes the generated site. Names are slash
+// separated and relative to the root of the site, e.g. "blog/index.html".
+type Output interface {
+	FileWriter
+
+	// Remove deletes the file or directory name, along with any directories
+	// it leaves empty. Removing something that does not exist is not an
+	// error.
+	Remove(name string) error
+
+	// Stage starts a full build. Nothing written to the stage shows up in
+	// the output until it is published, which replaces everything in the
+	// output at once.
+	Stage() (Stage, error)
+}
+
+// Stage is a full build in progress; see Output.Stage.
+type Stage interface {
+	FileWriter
+
+	// Publish replaces the contents of the output with the stage.
+	Publish() error
+
+	// Discard throws the stage away, leaving the output untouched.
+	Discard() error
+}
+
+// FileWriter is anything a build writes files to.
+type FileWriter interface {
+	// WriteFile creates or replaces the file name.
+	WriteFile(name string, data []byte) error
+}
+
+// DirOutput writes the site to a directory on disk.
+type DirOutput struct {
+	Dir string
+}
+
+// NewDirOutput returns an Output that writes to dir.
+func NewDirOutput(dir string) *DirOutput {
+	return &DirOutput{Dir: dir}
+}
+
+func (o *DirOutput) WriteFile(name string, data []byte) error {
+	outPath := filepath.Join(o.Dir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, data, 0644)
+}
+
+func (o *DirOutput) Remove(name string) error {
+	outPath := filepath.Join(o.Dir, filepath.FromSlash(name))
+
+	if err := os.RemoveAll(outPath); err != nil {
+		return err
+	}
+
+	// clean up directories that are now empty, e.g. blog/post/ for blog/post/index.html
+	for dir := filepath.Dir(outPath); helpers.IsWithin(dir, o.Dir) && filepath.Clean(dir) != filepath.Clean(o.Dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Stage builds into a temporary directory next to Dir, which is swapped into
+// place on Publish, so a failed build never leaves Dir empty or half-written.
+func (o *DirOutput) Stage() (Stage, error) {
+	if err := os.MkdirAll(filepath.Dir(o.Dir), 0755); err != nil {
+		return nil, err
+	}
+
+	stagingDir, err := os.MkdirTemp(filepath.Dir(o.Dir), "."+filepath.Base(o.Dir)+"-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(stagingDir, 0755); err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+
+	return &dirStage{DirOutput: DirOutput{Dir: stagingDir}, target: o.Dir}, nil
+}
+
+type dirStage struct {
+	DirOutput
+	target string
+}
+
+// Publish moves the staging directory to the target, replacing the previous
+// build. The previous build is only deleted once the new one is in place.
+//
+// On Linux both are swapped in one step. Elsewhere, or when the file system
+// cannot swap them, the previous build is moved aside first, so the target
+// is missing for the moment between the two renames.
+func (s *dirStage) Publish() error {
+	if err := exchange(s.Dir, s.target); err == nil {
+		// the staging directory now holds the previous build
+		os.RemoveAll(s.Dir)
+		return nil
+	}
+
+	backupDir := s.Dir + ".old"
+	hadPrevious := true
+
+	if err := os.Rename(s.target, backupDir); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("error moving previous build out of the way: %w", err)
+		}
+		hadPrevious = false
+	}
+
+	if err := os.Rename(s.Dir, s.target); err != nil {
+		if hadPrevious {
+			os.Rename(backupDir, s.target)
+		}
+		return fmt.Errorf("error moving new build into place: %w", err)
+	}
+
+	// the new build is in place; a leftover backup is only clutter
+	if hadPrevious {
+		os.RemoveAll(backupDir)
+	}
+	return nil
+}
+
+func (s *dirStage) Discard() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// MemoryOutput keeps the site in memory. It is an fs.FS, so it can be read
+// back with fs.ReadFile or served with http.FileServerFS.
+type MemoryOutput struct {
+	mu    sync.RWMutex
+	files fstest.MapFS
+}
+
+// NewMemoryOutput returns an empty MemoryOutput.
+func NewMemoryOutput() *MemoryOutput {
+	return &MemoryOutput{files: fstest.MapFS{}}
+}
+
+func (o *MemoryOutput) WriteFile(name string, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.files[path.Clean(name)] = memoryFile(data)
+	return nil
+}
+
+func (o *MemoryOutput) Remove(name string) error {
+	name = path.Clean(name)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for file := range o.files {
+		if file == name || strings.HasPrefix(file, name+"/") {
+			delete(o.files, file)
+		}
+	}
+	return nil
+}
+
+func (o *MemoryOutput) Stage() (Stage, error) {
+	return &memoryStage{output: o, files: fstest.MapFS{}}, nil
+}
+
+// Open implements fs.FS.
+func (o *MemoryOutput) Open(name string) (fs.File, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	// MapFS lists directories when they are opened, and files are never
+	// changed in place, so the returned file stays valid after unlocking
+	return o.files.Open(name)
+}
+
+type memoryStage struct {
+	output *MemoryOutput
+
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+func (s *memoryStage) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[path.Clean(name)] = memoryFile(data)
+	return nil
+}
+
+func (s *memoryStage) Publish() error {
+	s.output.mu.Lock()
+	defer s.output.mu.Unlock()
+
+	s.output.files = s.files
+	return nil
+}
+
+func (s *memoryStage) Discard() error {
+	return nil
+}
+
+func memoryFile(data []byte) *fstest.MapFile {
+	return &fstest.MapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    0644,
+		ModTime: time.Now(),
+	}
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirOutputPublish(t *testing.T) {
+	parent := t.TempDir()
+	out := &DirOutput{Dir: filepath.Join(parent, "build")}
+
+	for _, content := range []string{"first", "second"} {
+		stage, err := out.Stage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stage.WriteFile("index.html", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := stage.Publish(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(out.Dir, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("index.html = %q after publishing, want %q", got, content)
+		}
+	}
+
+	// neither the staging directories nor the previous builds are left behind
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d entries next to the build directory, want only the build directory", len(entries))
+	}
+}
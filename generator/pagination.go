@@ -0,0 +1,210 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// paginateAttr marks the element of a list template that holds the items of
+// a page, for fragments.
+const paginateAttr = "data-goose-paginate"
+
+// Paginator is one page of a paginated list.
+type Paginator struct {
+	PageNumber int
+	TotalPages int
+	TotalItems int
+
+	// Pages holds the items of this page.
+	Pages []*Page
+
+	// URLs of the other pages, empty when there is no such page.
+	First, Prev, Next, Last string
+
+	// NextFragment is the URL of the items of the next page on their own,
+	// when fragments are enabled.
+	NextFragment string
+}
+
+// paginate splits the pages a section or term page lists into pages of
+// Config.Paginate items, or of the paginate front matter of a section page.
+// The first page stays at the URL of the list, e.g. /blog/, and the others
+// go to /blog/page/2/ and so on. A list that fits on one page still gets a
+// Paginator, so templates work the same either way. It returns the pages
+// after the first, and their fragments when Config.PaginateFragments is set
+// and the list has a template of its own.
+func (b *Builder) paginate(list *Page) []*Page {
+	perPage := b.config.Paginate
+	if list.Kind == KindSection {
+		if value, ok := list.Meta["paginate"]; ok {
+			size, _ := frontMatterNumber(value) // false turns it off
+			perPage = int(size)
+		}
+	}
+
+	if perPage <= 0 || len(list.Pages) <= perPage {
+		list.Paginator = &Paginator{
+			PageNumber: 1,
+			TotalPages: 1,
+			TotalItems: len(list.Pages),
+			Pages:      list.Pages,
+			First:      list.URL,
+			Last:       list.URL,
+		}
+		return nil
+	}
+
+	// the built-in listing of a term page has no element to take the items
+	// of a fragment from
+	fragments := b.config.PaginateFragments && (list.template != "" || list.Meta["template"] != nil)
+
+	totalPages := (len(list.Pages) + perPage - 1) / perPage
+	base := listBase(list.URL)
+
+	pageURL := func(n int) string {
+		if n == 1 {
+			return list.URL
+		}
+		if b.config.PrettyURLs {
+			return fmt.Sprintf("%s/page/%d/", base, n)
+		}
+		return fmt.Sprintf("%s/page/%d.html", base, n)
+	}
+	fragmentURL := func(n int) string {
+		return fmt.Sprintf("%s/page/%d/fragment.html", base, n)
+	}
+
+	var pages []*Page
+	for n := 1; n <= totalPages; n++ {
+		start := (n - 1) * perPage
+		end := min(start+perPage, len(list.Pages))
+
+		paginator := &Paginator{
+			PageNumber: n,
+			TotalPages: totalPages,
+			TotalItems: len(list.Pages),
+			Pages:      list.Pages[start:end],
+			First:      pageURL(1),
+			Last:       pageURL(totalPages),
+		}
+		if n > 1 {
+			paginator.Prev = pageURL(n - 1)
+		}
+		if n < totalPages {
+			paginator.Next = pageURL(n + 1)
+			if fragments {
+				paginator.NextFragment = fragmentURL(n + 1)
+			}
+		}
+
+		if n == 1 {
+			list.Paginator = paginator
+			if list.Kind == KindTerm {
+				list.Content = pagedList(list.Title, paginator)
+			}
+			continue
+		}
+
+		pages = append(pages, b.pagedCopy(list, paginator, fmt.Sprintf("%s (page %d)", list.SourcePath, n), pageURL(n), false))
+		if fragments {
+			pages = append(pages, b.pagedCopy(list, paginator, fmt.Sprintf("%s (page %d fragment)", list.SourcePath, n), fragmentURL(n), true))
+		}
+	}
+
+	return pages
+}
+
+// pagedCopy returns a copy of list rendered at url with paginator.
+func (b *Builder) pagedCopy(list *Page, paginator *Paginator, source, url string, fragment bool) *Page {
+	page := *list
+	page.SourcePath = source
+	page.URL = url
+	page.OutputPath = filepath.Join(b.config.BuildDir, filepath.FromSlash(urlFile(url)))
+	page.Paginator = paginator
+	page.deps = append([]string{}, list.deps...)
+	page.fragment = fragment
+	if list.Kind == KindTerm {
+		page.Content = pagedList(list.Title, paginator)
+	}
+	return &page
+}
+
+// pagedList is pageList for one page of a paginated list, with links to the
+// pages before and after it.
+func pagedList(title string, paginator *Paginator) string {
+	list := pageList(title, paginator.Pages)
+
+	var links []string
+	if paginator.Prev != "" {
+		links = append(links, fmt.Sprintf("[Newer](%s)", paginator.Prev))
+	}
+	if paginator.Next != "" {
+		links = append(links, fmt.Sprintf("[Older](%s)", paginator.Next))
+	}
+	if len(links) > 0 {
+		list += "\n" + strings.Join(links, " · ") + "\n"
+	}
+	return list
+}
+
+// paginateFragment adds an element to the paginateAttr element of a rendered
+// list page that loads the next page with htmx once it scrolls into view, and
+// for a fragment, only keeps what is inside that element.
+func paginateFragment(page *Page, output []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+
+	var container *html.Node
+	walkElements(doc, func(n *html.Node) {
+		for _, attr := range n.Attr {
+			if attr.Key == paginateAttr && container == nil {
+				container = n
+			}
+		}
+	})
+	if container == nil {
+		if page.fragment {
+			return nil, fmt.Errorf("the template has no element with a %s attribute", paginateAttr)
+		}
+		return output, nil
+	}
+
+	if next := page.Paginator.NextFragment; next != "" {
+		tag := "div"
+		for c := container.LastChild; c != nil; c = c.PrevSibling {
+			if c.Type == html.ElementNode {
+				tag = c.Data
+				break
+			}
+		}
+
+		container.AppendChild(&html.Node{
+			Type: html.ElementNode,
+			Data: tag,
+			Attr: []html.Attribute{
+				{Key: "hx-get", Val: next},
+				{Key: "hx-trigger", Val: "revealed"},
+				{Key: "hx-swap", Val: "outerHTML"},
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	if !page.fragment {
+		err = html.Render(&buf, doc)
+		return buf.Bytes(), err
+	}
+
+	for c := container.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      int
+		paginate   int
+		meta       map[string]interface{}
+		totalPages int
+		urls       []string // of the pages after the first
+	}{
+		{"fits", 3, 5, nil, 1, nil},
+		{"exactly full", 4, 2, nil, 2, []string{"/blog/page/2/"}},
+		{"partly full", 5, 2, nil, 3, []string{"/blog/page/2/", "/blog/page/3/"}},
+		{"off", 5, 0, nil, 1, nil},
+		{"front matter", 5, 0, map[string]interface{}{"paginate": 4}, 2, []string{"/blog/page/2/"}},
+		{"turned off in front matter", 5, 2, map[string]interface{}{"paginate": false}, 1, nil},
+	}
+	for _, test := range tests {
+		cfg := DefaultConfig()
+		cfg.Paginate = test.paginate
+		cfg.Log = io.Discard
+		b := New(cfg)
+
+		list := &Page{Kind: KindSection, SourcePath: "source/pages/blog/index.md", URL: "/blog/", Meta: test.meta}
+		for i := 0; i < test.items; i++ {
+			list.Pages = append(list.Pages, &Page{Title: fmt.Sprint(i)})
+		}
+
+		pages := b.paginate(list)
+		if got := list.Paginator.TotalPages; got != test.totalPages {
+			t.Errorf("%s: TotalPages = %d, want %d", test.name, got, test.totalPages)
+		}
+		if len(pages) != len(test.urls) {
+			t.Errorf("%s: got %d pages after the first, want %d", test.name, len(pages), len(test.urls))
+			continue
+		}
+
+		seen := len(list.Paginator.Pages)
+		for i, page := range pages {
+			if page.URL != test.urls[i] {
+				t.Errorf("%s: page %d is at %s, want %s", test.name, i+2, page.URL, test.urls[i])
+			}
+			seen += len(page.Paginator.Pages)
+		}
+		if seen != test.items {
+			t.Errorf("%s: the pages list %d items, want %d", test.name, seen, test.items)
+		}
+	}
+}
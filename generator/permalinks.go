@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var permalinkTokenRe = regexp.MustCompile(`:([a-z]+)`)
+
+// permalink works out the URL of a page from its url and slug front matter
+// and the permalink pattern of its section, e.g. "/blog/:year/:month/:slug/".
+// It returns "" when none of them apply and the page keeps the URL that
+// follows from its file name.
+func (b *Builder) permalink(relPath, section string, metadata map[string]interface{}) (string, error) {
+	if url, ok := metadata["url"].(string); ok && url != "" {
+		return "/" + strings.TrimPrefix(url, "/"), nil
+	}
+
+	dir := filepath.ToSlash(filepath.Dir(relPath))
+	filename := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+
+	slug, hasSlug := metadata["slug"].(string)
+	if !hasSlug || slug == "" {
+		slug = filename
+		if filename == "index" && dir != "." {
+			slug = path.Base(dir)
+		}
+	}
+
+	// index pages stand for their directory, so they keep its URL
+	pattern, hasPattern := b.permalinks[strings.ToLower(section)]
+	if !hasPattern || filename == "index" {
+		if !hasSlug || filename == "index" {
+			return "", nil
+		}
+
+		pattern = "/:dir/:slug/"
+		if !b.config.PrettyURLs {
+			pattern = "/:dir/:slug.html"
+		}
+	}
+
+	date, _, _, _, err := b.pageDates(metadata)
+	if err != nil {
+		return "", err
+	}
+	hasDate := !date.IsZero()
+
+	var missing error
+	url := permalinkTokenRe.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token[1:] {
+		case "year":
+			if !hasDate {
+				missing = fmt.Errorf("permalink %q needs a date in the front matter", pattern)
+			}
+			return date.Format("2006")
+		case "month":
+			if !hasDate {
+				missing = fmt.Errorf("permalink %q needs a date in the front matter", pattern)
+			}
+			return date.Format("01")
+		case "day":
+			if !hasDate {
+				missing = fmt.Errorf("permalink %q needs a date in the front matter", pattern)
+			}
+			return date.Format("02")
+		case "slug":
+			return slug
+		case "section":
+			return section
+		case "filename":
+			return filename
+		case "dir":
+			return dir
+		default:
+			missing = fmt.Errorf("permalink %q has an unknown token %s", pattern, token)
+			return token
+		}
+	})
+	if missing != nil {
+		return "", missing
+	}
+
+	return "/" + strings.TrimPrefix(path.Clean(url), "/") + trailingSlash(url), nil
+}
+
+// urlFile returns the name of the file a URL is served from: URLs ending in
+// a slash or without an extension get an index.html.
+func urlFile(url string) string {
+	name := strings.TrimPrefix(path.Clean("/"+url), "/")
+	if strings.HasSuffix(url, "/") || path.Ext(url) == "" {
+		return path.Join(name, "index.html")
+	}
+	return name
+}
+
+func trailingSlash(url string) string {
+	if strings.HasSuffix(url, "/") && url != "/" {
+		return "/"
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"io"
+	"testing"
+)
+
+func TestPermalink(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Permalinks = map[string]string{"blog": "/:section/:year/:month/:slug/"}
+	cfg.Log = io.Discard
+	b := New(cfg)
+
+	tests := []struct {
+		relPath  string
+		section  string
+		metadata map[string]interface{}
+		want     string
+		wantErr  bool
+	}{
+		{"about.md", "", nil, "", false},
+		{"about.md", "", map[string]interface{}{"url": "team/"}, "/team/", false},
+		{"about.md", "", map[string]interface{}{"slug": "us"}, "/us/", false},
+		{"docs/guide.md", "docs", map[string]interface{}{"slug": "intro"}, "/docs/intro/", false},
+		{"blog/post.md", "blog", map[string]interface{}{"date": "2024-03-05"}, "/blog/2024/03/post/", false},
+		{"blog/post.md", "blog", map[string]interface{}{"date": "2024-03-05", "slug": "hello"}, "/blog/2024/03/hello/", false},
+		{"blog/index.md", "blog", nil, "", false},
+		{"blog/post.md", "blog", nil, "", true},
+	}
+	for _, test := range tests {
+		got, err := b.permalink(test.relPath, test.section, test.metadata)
+		if (err != nil) != test.wantErr {
+			t.Errorf("permalink(%q, %v) returned error %v", test.relPath, test.metadata, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("permalink(%q, %v) = %q, want %q", test.relPath, test.metadata, got, test.want)
+		}
+	}
+}
+
+func TestURLFile(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/", "index.html"},
+		{"/blog/", "blog/index.html"},
+		{"/blog/post", "blog/post/index.html"},
+		{"/blog/post.html", "blog/post.html"},
+		{"/feed.xml", "feed.xml"},
+		{"/../escape/", "escape/index.html"},
+	}
+	for _, test := range tests {
+		if got := urlFile(test.url); got != test.want {
+			t.Errorf("urlFile(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
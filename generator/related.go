@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+)
+
+// what pages can have in common besides their taxonomies, as keys of
+// Config.RelatedWeights
+const (
+	relatedSection  = "section"
+	relatedKeywords = "keywords"
+)
+
+// relatedPages hands every page the Config.RelatedCount pages that have the
+// most in common with it. Each shared term of a taxonomy, a shared section
+// and each shared keyword front matter value adds its weight from
+// Config.RelatedWeights to the score of the other page. Pages that have
+// nothing in common are left out, and ties go to the newer page.
+func (b *Builder) relatedPages() {
+	weights := b.config.RelatedWeights
+
+	var pages []*Page
+	keywords := make(map[*Page]map[string]bool)
+	for _, page := range b.site.Pages {
+		page.Related = nil
+		if page.Kind == KindPage {
+			pages = append(pages, page)
+			keywords[page] = pageKeywords(page.Meta)
+		}
+	}
+
+	if b.config.RelatedCount <= 0 {
+		return
+	}
+
+	for _, page := range pages {
+		scores := make(map[*Page]float64)
+		var related []*Page
+		for _, other := range pages {
+			if other == page {
+				continue
+			}
+
+			var score float64
+			for _, name := range b.config.Taxonomies {
+				for _, term := range page.Terms[name] {
+					for _, otherTerm := range other.Terms[name] {
+						if term == otherTerm {
+							score += weights[name]
+						}
+					}
+				}
+			}
+			if page.Section != "" && page.Section == other.Section {
+				score += weights[relatedSection]
+			}
+			for keyword := range keywords[page] {
+				if keywords[other][keyword] {
+					score += weights[relatedKeywords]
+				}
+			}
+
+			if score > 0 {
+				scores[other] = score
+				related = append(related, other)
+			}
+		}
+
+		sort.SliceStable(related, func(i, j int) bool {
+			pi, pj := related[i], related[j]
+			if scores[pi] != scores[pj] {
+				return scores[pi] > scores[pj]
+			}
+			if !pi.Date.Equal(pj.Date) {
+				return pi.Date.After(pj.Date)
+			}
+			return pi.Title < pj.Title
+		})
+
+		if len(related) > b.config.RelatedCount {
+			related = related[:b.config.RelatedCount]
+		}
+		page.Related = related
+	}
+}
+
+// pageKeywords returns the keywords front matter of a page in lower case,
+// either a list or a comma-separated string.
+func pageKeywords(metadata map[string]interface{}) map[string]bool {
+	values := frontMatterList(metadata[relatedKeywords])
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+
+	keywords := make(map[string]bool)
+	for _, value := range values {
+		if keyword := strings.ToLower(strings.TrimSpace(value)); keyword != "" {
+			keywords[keyword] = true
+		}
+	}
+	return keywords
+}
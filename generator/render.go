@@ -0,0 +1,607 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tdewolff/minify/v2"
+	minifycss "github.com/tdewolff/minify/v2/css"
+	minifyhtml "github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"golang.org/x/net/html"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// renderPage renders a single loaded page into buildDir and records
+// everything it read in the dependency graph.
+func (b *Builder) renderPage(page *Page, logs io.Writer) error {
+	cfg := b.config
+	logger := log.New(logs, "", log.LstdFlags)
+
+	// warnings are cached along with the page, so they show up again (and
+	// fail --strict builds) when it comes from the cache
+	var warnings []*BuildError
+	warn := func(warning *BuildError) {
+		warnings = append(warnings, warning)
+		b.report.warn(logger, warning)
+	}
+
+	path := page.SourcePath
+	outPath := page.OutputPath
+	linked := linkedSources(page)
+	deps := append([]string{}, page.deps...)
+	for _, source := range linked {
+		if source != "" {
+			deps = append(deps, source)
+		}
+	}
+	defer func() {
+		b.deps.record(path, outPath, deps)
+	}()
+
+	if previousOutPath := b.deps.forget(path); previousOutPath != "" && previousOutPath != outPath {
+		if err := b.output.Remove(b.outputName(previousOutPath)); err != nil {
+			logger.Printf("Error removing %s: %v\n", previousOutPath, err)
+		}
+	}
+
+	fmt.Fprintf(logs, "Rendering %s... ", path)
+
+	// generated pages list other pages, which the cache does not track
+	cache := b.cache
+	if page.Kind != KindPage {
+		cache = nil
+	}
+
+	// pages whose template can reach other pages depend on all of them
+	var site string
+	if cache != nil && b.readsSite(page) {
+		site = b.site.fingerprint
+	}
+
+	if cache != nil {
+		if entry, output, markdown, ok := cache.lookup(path, linked, site); ok && entry.OutPath == outPath {
+			page.html = markdown
+
+			for dep := range entry.Deps {
+				deps = append(deps, dep)
+			}
+			for _, warning := range entry.Warnings {
+				b.report.warn(logger, warning.buildError(path))
+			}
+
+			if err := b.out.WriteFile(b.outputName(outPath), output); err != nil {
+				return newBuildError(path, stageWrite, outPath, err)
+			}
+
+			fmt.Fprintln(logs, "cached.")
+			return nil
+		}
+	}
+
+	metadata := page.Meta
+
+	markdown, toc, referenced, err := b.renderMarkdown(page, func(file string, err error) {
+		warn(newBuildError(path, stageMarkdown, file, err))
+	})
+	deps = append(deps, referenced...)
+	if err != nil {
+		return newBuildError(path, stageMarkdown, path, err)
+	}
+	page.html = markdown
+
+	title := page.Title
+
+	var css []byte
+	if metadata["styles"] != nil {
+		styles, ok := metadata["styles"].([]interface{})
+		if !ok {
+			return newBuildError(path, stageFrontMatter, path, fmt.Errorf("styles must be a list"))
+		}
+
+		for _, style := range helpers.RemoveDuplicates(styles) {
+			fileName := filepath.Base(fmt.Sprintf("%v", style))
+			if !strings.HasSuffix(fileName, ".css") {
+				fileName += ".css"
+			}
+
+			deps = append(deps, filepath.Join(b.stylesDir, fileName))
+			if exists, err := b.isFile(filepath.Join(b.stylesDir, fileName)); exists &&
+				err == nil {
+				addedCSS, err := b.readFile(
+					filepath.Join(b.stylesDir, fileName),
+				)
+
+				if err != nil {
+					return newBuildError(path, stageAssets, filepath.Join(b.stylesDir, fileName), err)
+				}
+
+				css = append(css, append(addedCSS, "\n"...)...)
+			} else {
+				warn(newBuildError(path, stageAssets, filepath.Join(b.stylesDir, fileName), fmt.Errorf("stylesheet %s does not exist", fileName)))
+			}
+		}
+	} else {
+		for _, style := range cfg.DefaultStyles {
+			deps = append(deps, filepath.Join(b.stylesDir, style))
+			if exists, err := b.isFile(filepath.Join(b.stylesDir, style)); exists && err == nil {
+				addedCSS, err := b.readFile(
+					filepath.Join(b.stylesDir, style),
+				)
+				if err != nil {
+					return newBuildError(path, stageAssets, filepath.Join(b.stylesDir, style), err)
+				}
+
+				css = append(css, append(addedCSS, "\n"...)...)
+			} else {
+				warn(newBuildError(path, stageAssets, filepath.Join(b.stylesDir, style), fmt.Errorf("default stylesheet %s does not exist; proceeding to not use a stylesheet", style)))
+			}
+		}
+	}
+
+	var scripts [][]byte
+	if metadata["scripts"] != nil {
+		pageScripts, ok := metadata["scripts"].([]interface{})
+		if !ok {
+			return newBuildError(path, stageFrontMatter, path, fmt.Errorf("scripts must be a list"))
+		}
+
+		for _, style := range helpers.RemoveDuplicates(pageScripts) {
+			fileName := filepath.Base(fmt.Sprintf("%v", style))
+			if !strings.HasSuffix(fileName, ".js") {
+				fileName += ".js"
+			}
+
+			deps = append(deps, filepath.Join(b.scriptsDir, fileName))
+			if exists, err := b.isFile(filepath.Join(b.scriptsDir, fileName)); exists &&
+				err == nil {
+				addedJS, err := b.readFile(
+					filepath.Join(b.scriptsDir, fileName),
+				)
+
+				if err != nil {
+					return newBuildError(path, stageAssets, filepath.Join(b.scriptsDir, fileName), err)
+				}
+
+				scripts = append(scripts, addedJS)
+			} else {
+				warn(newBuildError(path, stageAssets, filepath.Join(b.scriptsDir, fileName), fmt.Errorf("script %s does not exist", fileName)))
+			}
+		}
+	} else {
+		for _, script := range cfg.DefaultScripts {
+			deps = append(deps, filepath.Join(b.scriptsDir, script))
+			if exists, err := b.isFile(filepath.Join(b.scriptsDir, script)); exists && err == nil {
+				addedJS, err := b.readFile(
+					filepath.Join(b.scriptsDir, script),
+				)
+				if err != nil {
+					return newBuildError(path, stageAssets, filepath.Join(b.scriptsDir, script), err)
+				}
+
+				scripts = append(scripts, addedJS)
+			} else {
+				warn(newBuildError(path, stageAssets, filepath.Join(b.scriptsDir, script), fmt.Errorf("default script %s does not exist; proceeding to not use a script", script)))
+			}
+		}
+	}
+
+	var templateBytes []byte
+	var templatePath string
+	deps = append(deps, filepath.Join(b.templatesDir, cfg.DefaultTemplate))
+	if exists, err := b.isFile(filepath.Join(b.templatesDir, cfg.DefaultTemplate)); exists &&
+		err == nil {
+		templateName := metadata["template"]
+		if templateName == nil && page.template != "" {
+			templateName = page.template
+		}
+
+		if templateName != nil {
+			fileName := filepath.Base(fmt.Sprintf("%v", templateName))
+			if !strings.HasSuffix(fileName, ".html") {
+				fileName += ".html"
+			}
+
+			deps = append(deps, filepath.Join(b.templatesDir, fileName))
+			if exists, err := b.isFile(filepath.Join(b.templatesDir, fileName)); exists &&
+				err == nil {
+				templatePath = filepath.Join(b.templatesDir, fileName)
+				templateBytes, err = b.readFile(templatePath)
+				if err != nil {
+					return newBuildError(path, stageTemplate, templatePath, err)
+				}
+			} else {
+				warn(newBuildError(path, stageTemplate, filepath.Join(b.templatesDir, fileName), fmt.Errorf("template %s does not exist", fileName)))
+			}
+		} else {
+			templatePath = filepath.Join(b.templatesDir, cfg.DefaultTemplate)
+			templateBytes, err = b.readFile(templatePath)
+			if err != nil {
+				return newBuildError(path, stageTemplate, templatePath, err)
+			}
+		}
+	} else {
+		templateBytes = []byte(`<!doctypehtml><html lang="en"><meta charset="UTF-8"><meta content="width=device-width,initial-scale=1"name="viewport"><body><markdown></markdown>`)
+		warn(newBuildError(path, stageTemplate, filepath.Join(b.templatesDir, cfg.DefaultTemplate), fmt.Errorf("default template does not exist; proceeding to not use a template")))
+	}
+
+	// the template is parsed again after the DOM is rewritten below, but
+	// only the original file has line numbers that mean anything to the user
+	if _, err := template.New(templatePath).Funcs(b.templateFuncs()).Parse(string(templateBytes)); err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+
+	// template actions are set aside while the DOM is rewritten, as
+	// rendering it would escape the quotes in them
+	var actions []string
+	templateBytes = templateActionRe.ReplaceAllFunc(templateBytes, func(action []byte) []byte {
+		actions = append(actions, string(action))
+		return []byte(fmt.Sprintf(actionPlaceholder, len(actions)-1))
+	})
+
+	doc, err := html.Parse(bytes.NewReader(templateBytes))
+	if err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "head":
+				titleNode := &html.Node{
+					Type: html.ElementNode,
+					Data: "title",
+					FirstChild: &html.Node{
+						Type: html.TextNode,
+						Data: title,
+					},
+				}
+				n.AppendChild(titleNode)
+
+				if b.baseURL != "" {
+					for _, link := range b.feedsHook().feedLinks(page) {
+						n.AppendChild(&html.Node{
+							Type: html.ElementNode,
+							Data: "link",
+							Attr: []html.Attribute{
+								{Key: "rel", Val: "alternate"},
+								{Key: "type", Val: link[0]},
+								{Key: "href", Val: link[1]},
+							},
+						})
+					}
+				}
+
+				styleNode := &html.Node{
+					Type: html.ElementNode,
+					Data: "style",
+					FirstChild: &html.Node{
+						Type: html.TextNode,
+						Data: string(css),
+					},
+				}
+				if cfg.LiveReloadScript != "" {
+					styleNode.Attr = append(styleNode.Attr, html.Attribute{Key: "data-goose-style"})
+				}
+				n.AppendChild(styleNode)
+
+				for _, script := range scripts {
+					scriptsNode := &html.Node{
+						Type: html.ElementNode,
+						Data: "script",
+						FirstChild: &html.Node{
+							Type: html.TextNode,
+							Data: string(script),
+						},
+					}
+					n.AppendChild(scriptsNode)
+				}
+
+				/*case markdownPlaceholderTag:
+				if n.Parent == nil {
+					logger.Printf("Warning: <markdown> tag found without parent in %s", path)
+					break
+				}
+
+				markdownNodes, parseErr := html.ParseFragment(
+					bytes.NewReader([]byte(markdown)),
+					n.Parent,
+				)
+				if parseErr != nil {
+					logger.Printf(
+						"Error parsing markdown HTML fragment for %s: %v. Inserting raw content.",
+						path,
+						parseErr,
+					)
+
+					errorNode := &html.Node{
+						Type: html.ElementNode,
+						Data: "div",
+						Attr: []html.Attribute{
+							{
+								Key: "style",
+								Val: "color:red; border: 1px solid red; padding: 1em;",
+							},
+						},
+						FirstChild: &html.Node{
+							Type: html.TextNode,
+							Data: fmt.Sprintf(
+								"Error processing markdown content: %v",
+								parseErr,
+							),
+						},
+					}
+					n.Parent.InsertBefore(errorNode, n)
+				} else {
+					for _, newNode := range markdownNodes {
+						n.Parent.InsertBefore(newNode, n)
+					}
+				}
+
+				n.Parent.RemoveChild(n)*/
+			}
+		}
+
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			walk(c)
+			c = next
+		}
+	}
+
+	walk(doc)
+
+	if err := b.onDocument(page, doc); err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+
+	var buf bytes.Buffer
+	err = html.Render(&buf, doc)
+	if err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+
+	renderedHtml := actionPlaceholderRe.ReplaceAllStringFunc(buf.String(), func(placeholder string) string {
+		var i int
+		fmt.Sscanf(placeholder, actionPlaceholder, &i)
+		return actions[i]
+	})
+
+	tmpl, err := template.New("").Funcs(b.templateFuncs()).Parse(renderedHtml)
+	if err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+	var output bytes.Buffer
+	data := map[any]any{
+		"Markdown":        template.HTML(markdown),
+		"Page":            page,
+		"Site":            b.site,
+		"Environment":     cfg.Environment,
+		"TOC":             toc,
+		"TableOfContents": template.HTML(helpers.TOCHTML(toc)),
+	}
+	for k, v := range metadata {
+		data[k] = v
+	}
+	if err := tmpl.Execute(&output, data); err != nil {
+		// line numbers refer to the rewritten DOM, not the template file
+		buildErr := newBuildError(path, stageTemplate, templatePath, err)
+		buildErr.Line = 0
+		return buildErr
+	}
+
+	executed, err := b.afterTemplate(page, output.Bytes())
+	if err != nil {
+		return newBuildError(path, stageTemplate, templatePath, err)
+	}
+
+	if cfg.PaginateFragments && page.Paginator != nil {
+		executed, err = paginateFragment(page, executed)
+		if err != nil {
+			return newBuildError(path, stageTemplate, templatePath, err)
+		}
+	}
+
+	minifier := minify.New()
+	htmlMinifier := &minifyhtml.Minifier{
+		KeepDocumentTags:        true,
+		KeepEndTags:             false,
+		KeepConditionalComments: false,
+		KeepQuotes:              false,
+		KeepWhitespace:          false,
+	}
+	minifier.AddFunc("text/html", htmlMinifier.Minify)
+	minifier.AddFunc("text/css", minifycss.Minify)
+	minifier.AddFunc("text/javascript", js.Minify)
+
+	var minifedHTML string
+	if cfg.MinifyOutput {
+		minifedHTML, err = minifier.String("text/html", string(executed))
+		if err != nil {
+			return newBuildError(path, stageMinify, path, err)
+		}
+	} else {
+		minifedHTML = string(executed)
+	}
+
+	final, err := b.beforeWrite(page, []byte(minifedHTML))
+	if err != nil {
+		return newBuildError(path, stageWrite, outPath, err)
+	}
+
+	if err := b.out.WriteFile(b.outputName(outPath), final); err != nil {
+		return newBuildError(path, stageWrite, outPath, err)
+	}
+
+	if cache != nil {
+		cache.store(path, outPath, deps, linked, site, warnings, markdown, final)
+	}
+
+	fmt.Fprintln(logs, "generated.")
+	return nil
+}
+
+// readsSite reports whether the template of a page can reach other pages,
+// through .Site or the pages of its terms.
+func (b *Builder) readsSite(page *Page) bool {
+	templatePath := filepath.Join(b.templatesDir, b.config.DefaultTemplate)
+
+	templateName := page.Meta["template"]
+	if templateName == nil && page.template != "" {
+		templateName = page.template
+	}
+	if templateName != nil {
+		fileName := filepath.Base(fmt.Sprintf("%v", templateName))
+		if !strings.HasSuffix(fileName, ".html") {
+			fileName += ".html"
+		}
+		templatePath = filepath.Join(b.templatesDir, fileName)
+	}
+
+	content, err := b.readFile(templatePath)
+	return err == nil && siteFieldRe.Match(content)
+}
+
+var siteFieldRe = regexp.MustCompile(`\.(Site|Terms)\b`)
+
+var (
+	templateActionRe    = regexp.MustCompile(`(?s){{.*?}}`)
+	actionPlaceholderRe = regexp.MustCompile("\uE000goose-action-[0-9]+\uE001")
+)
+
+const actionPlaceholder = "\uE000goose-action-%d\uE001"
+
+var tocPlaceholderRe = regexp.MustCompile(`(<p>)?{{\s*toc\s*}}(</p>)?`)
+
+// renderMarkdown turns the Markdown of a page into HTML, filling in its
+// placeholders and running every MarkdownHook. It also returns the table of
+// contents of the page, and the pages the placeholders read.
+func (b *Builder) renderMarkdown(page *Page, warn func(file string, err error)) (string, []*helpers.Heading, []string, error) {
+	cfg := b.config
+
+	markdown, toc, err := helpers.RenderMarkdownTOC(
+		page.Content,
+		helpers.MarkdownConfig{
+			Theme:                                 cfg.SyntaxHighlightingStyle,
+			SyntaxHighlightingUseCustomBackground: cfg.SyntaxHighlightingUseCustomBackground,
+			SyntaxHighlightingCustomBackground:    cfg.SyntaxHighlightingCustomBackground,
+			EnableCodeBlockLineNumbers:            cfg.EnableCodeBlockLineNumbers,
+			EnableEmoji:                           cfg.EnableEmoji,
+		},
+		cfg.TOCMinLevel,
+		cfg.TOCMaxLevel,
+	)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if page.Meta["toc"] == false {
+		toc = nil
+	}
+	tocHTML := helpers.TOCHTML(toc)
+	markdown = tocPlaceholderRe.ReplaceAllLiteralString(markdown, tocHTML)
+
+	markdown, referenced := replaceMetaPlaceholders(
+		markdown,
+		b.defaultMetadata,
+		page.Meta,
+		b.site,
+		helpers.MarkdownConfig{
+			Theme:                                 cfg.SyntaxHighlightingStyle,
+			SyntaxHighlightingUseCustomBackground: cfg.SyntaxHighlightingUseCustomBackground,
+			SyntaxHighlightingCustomBackground:    cfg.SyntaxHighlightingCustomBackground,
+			EnableCodeBlockLineNumbers:            cfg.EnableCodeBlockLineNumbers,
+			EnableEmoji:                           cfg.EnableEmoji,
+		},
+		filepath.Dir(page.SourcePath),
+		b.pagesDir,
+		b.readFile,
+		warn,
+	)
+
+	markdown, err = b.afterMarkdown(page, markdown)
+	return markdown, toc, referenced, err
+}
+
+func replaceMetaPlaceholders(
+	markdown string,
+	defaultMetadata, metadata map[string]interface{}, site *Site, config helpers.MarkdownConfig,
+	fileRootDir, rootDir string,
+	readFile func(path string) ([]byte, error),
+	warn func(file string, err error),
+) (string, []string) {
+	re := regexp.MustCompile(`{{\s*\.meta\.([a-zA-Z0-9_-]+)\s*}}`)
+	reFromFile := regexp.MustCompile(`{{\s*from\s+([^\s]+)\s+\.meta\.([a-zA-Z0-9_-]+)\s*}}`)
+
+	var referenced []string
+
+	markdown = re.ReplaceAllStringFunc(markdown, func(match string) string {
+		key := re.FindStringSubmatch(match)[1]
+		if value, ok := metadata[key]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+
+	markdown = reFromFile.ReplaceAllStringFunc(markdown, func(match string) string {
+		matches := reFromFile.FindStringSubmatch(match)
+		if len(matches) != 3 {
+			return match // invalid format
+		}
+
+		filePath := matches[1]
+
+		if !strings.HasSuffix(filePath, ".md") {
+			filePath += ".md"
+		}
+
+		metaKey := matches[2]
+
+		// resolve relative path based on the current file's directory
+		absFilePath := filepath.Join(fileRootDir, filePath)
+
+		// detect if absfilepath is outside root directory of pages (of the whole project, not the root of the file)
+		if !strings.HasPrefix(absFilePath, rootDir) {
+			warn(absFilePath, fmt.Errorf("file is outside the root directory %s", rootDir))
+			return match
+		}
+
+		referenced = append(referenced, absFilePath)
+
+		var referencedMetadata map[string]interface{}
+		if referencedPage := site.page(absFilePath); referencedPage != nil {
+			referencedMetadata = referencedPage.Meta
+		} else {
+			// not part of the build, e.g. a draft
+			content, err := readFile(absFilePath)
+			if err != nil {
+				warn(absFilePath, err)
+				return match
+			}
+
+			referencedMetadata, err = helpers.ExtractMetadata(
+				string(content),
+				config,
+				defaultMetadata,
+			)
+			if err != nil {
+				warn(absFilePath, err)
+			}
+		}
+
+		if value, ok := referencedMetadata[metaKey]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+
+	return markdown, referenced
+}
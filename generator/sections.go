@@ -0,0 +1,240 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const listTemplate = "list.html"
+
+// ways to sort the pages of a section
+const (
+	sortByDate        = "date"
+	sortByPublishDate = "publishDate"
+	sortByLastmod     = "lastmod"
+	sortByWeight      = "weight"
+	sortByTitle       = "title"
+)
+
+// linkSections finds the section pages of the site, i.e. blog.md or
+// blog/index.md for the pages under blog/, and hands each one the pages
+// directly under its directory. Subsections are listed by their own section
+// page. It returns the section pages.
+func (b *Builder) linkSections() []*Page {
+	listed := make(map[string]*Page)
+	for _, page := range b.site.Pages {
+		if page.Kind == KindSection {
+			page.Kind = KindPage
+		}
+		page.Pages = nil
+		page.template = ""
+
+		if dir := b.pageDir(page); dir != "." {
+			listed[dir] = page
+		}
+	}
+
+	var sections []*Page
+	for _, page := range b.site.Pages {
+		parent := b.pageParent(page)
+		section, ok := listed[parent]
+		if !ok || section == page {
+			continue
+		}
+
+		if section.Kind != KindSection {
+			section.Kind = KindSection
+			sections = append(sections, section)
+		}
+		section.Pages = append(section.Pages, page)
+	}
+
+	listTemplatePath := filepath.Join(b.templatesDir, listTemplate)
+	hasListTemplate, err := b.isFile(listTemplatePath)
+	hasListTemplate = hasListTemplate && err == nil
+
+	for _, section := range sections {
+		by := b.config.SectionSort
+		if value, ok := section.Meta["sort"]; ok {
+			by = fmt.Sprintf("%v", value)
+		}
+
+		if err := sortPages(section.Pages, by); err != nil {
+			b.report.warn(b.logger, newBuildError(section.SourcePath, stageFrontMatter, section.SourcePath, err))
+			sortPages(section.Pages, sortByDate)
+		}
+
+		section.deps = append(section.deps, listTemplatePath)
+		if hasListTemplate {
+			section.template = listTemplate
+		}
+	}
+
+	return sections
+}
+
+// linkNeighbours sets Prev and Next on every page in a directory under
+// pagesDir to the pages before and after it: by weight when any of them has
+// one and by date otherwise, or in the order the section page of the
+// directory lists them when it has a sort front matter. The prev and next
+// front matter of a page override them with the path of another page, e.g.
+// docs/install, or false for no link.
+func (b *Builder) linkNeighbours(sections []*Page) {
+	bySection := make(map[string]*Page)
+	for _, section := range sections {
+		bySection[b.pageDir(section)] = section
+	}
+
+	dirs := make(map[string][]*Page)
+	for _, page := range b.site.Pages {
+		page.Prev, page.Next = nil, nil
+		if parent := b.pageParent(page); parent != "." {
+			dirs[parent] = append(dirs[parent], page)
+		}
+	}
+
+	for dir, pages := range dirs {
+		if section, ok := bySection[dir]; ok && section.Meta["sort"] != nil {
+			pages = section.Pages
+		} else {
+			by := sortByDate
+			for _, page := range pages {
+				if _, ok := pageWeight(page.Meta); ok {
+					by = sortByWeight
+					break
+				}
+			}
+			sortPages(pages, by)
+		}
+
+		for i, page := range pages {
+			if i > 0 {
+				page.Prev = pages[i-1]
+			}
+			if i < len(pages)-1 {
+				page.Next = pages[i+1]
+			}
+		}
+	}
+
+	for _, page := range b.site.Pages {
+		for _, key := range []string{"prev", "next"} {
+			value, ok := page.Meta[key]
+			if !ok {
+				continue
+			}
+
+			var neighbour *Page
+			if value != false {
+				name := fmt.Sprintf("%v", value)
+				if !strings.HasSuffix(name, ".md") {
+					name += ".md"
+				}
+
+				neighbour = b.site.page(filepath.Join(b.pagesDir, name))
+				if neighbour == nil {
+					b.report.warn(b.logger, newBuildError(page.SourcePath, stageFrontMatter, page.SourcePath,
+						fmt.Errorf("%s %v is not a page of this site", key, value)))
+					continue
+				}
+			}
+
+			if key == "prev" {
+				page.Prev = neighbour
+			} else {
+				page.Next = neighbour
+			}
+		}
+	}
+}
+
+// pageDir returns the directory under pagesDir a page stands for: blog for
+// blog.md and blog/index.md.
+func (b *Builder) pageDir(page *Page) string {
+	relPath, err := filepath.Rel(b.pagesDir, page.SourcePath)
+	if err != nil {
+		return "."
+	}
+
+	name := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+	if filepath.Base(name) == "index" {
+		return filepath.Dir(name)
+	}
+	return name
+}
+
+// pageParent returns the directory under pagesDir that contains a page, where
+// blog/index.md counts as being in the parent of blog/ like blog.md does.
+func (b *Builder) pageParent(page *Page) string {
+	return filepath.Dir(b.pageDir(page))
+}
+
+// sortPages sorts pages by date, publishDate or lastmod (newest first),
+// weight (lowest first, pages without a weight last) or title. Ties are
+// broken by date and then title.
+func sortPages(pages []*Page, by string) error {
+	var less func(a, b *Page) (bool, bool)
+	switch by {
+	case sortByDate:
+		less = func(a, b *Page) (bool, bool) { return false, false }
+	case sortByPublishDate:
+		less = func(a, b *Page) (bool, bool) {
+			return a.PublishDate.After(b.PublishDate), !a.PublishDate.Equal(b.PublishDate)
+		}
+	case sortByLastmod:
+		less = func(a, b *Page) (bool, bool) {
+			return a.Lastmod.After(b.Lastmod), !a.Lastmod.Equal(b.Lastmod)
+		}
+	case sortByWeight:
+		less = func(a, b *Page) (bool, bool) {
+			wa, hasA := pageWeight(a.Meta)
+			wb, hasB := pageWeight(b.Meta)
+			if hasA != hasB {
+				return hasA, true
+			}
+			return wa < wb, wa != wb
+		}
+	case sortByTitle:
+		less = func(a, b *Page) (bool, bool) {
+			ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title)
+			return ta < tb, ta != tb
+		}
+	default:
+		return fmt.Errorf("cannot sort pages by %q; use date, publishDate, lastmod, weight or title", by)
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		if isLess, decided := less(pages[i], pages[j]); decided {
+			return isLess
+		}
+
+		di, dj := pages[i].Date, pages[j].Date
+		if !di.Equal(dj) {
+			return di.After(dj)
+		}
+		return pages[i].Title < pages[j].Title
+	})
+	return nil
+}
+
+// pageWeight returns the weight in a page's front matter.
+func pageWeight(metadata map[string]interface{}) (float64, bool) {
+	return frontMatterNumber(metadata["weight"])
+}
+
+// frontMatterNumber returns a front matter value that is a number.
+func frontMatterNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortPages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	pages := func() []*Page {
+		return []*Page{
+			{Title: "b", Date: day(2), Lastmod: day(9), PublishDate: day(2), Meta: map[string]interface{}{"weight": 2}},
+			{Title: "C", Date: day(3), Lastmod: day(3), PublishDate: day(5), Meta: map[string]interface{}{}},
+			{Title: "a", Date: day(1), Lastmod: day(1), PublishDate: day(1), Meta: map[string]interface{}{"weight": 1}},
+			{Title: "d", Date: day(3), Lastmod: day(3), PublishDate: day(3), Meta: map[string]interface{}{}},
+		}
+	}
+
+	tests := []struct {
+		by      string
+		want    string
+		wantErr bool
+	}{
+		{sortByDate, "C d b a", false},
+		{sortByPublishDate, "C d b a", false},
+		{sortByLastmod, "b C d a", false},
+		{sortByWeight, "a b C d", false},
+		{sortByTitle, "a b C d", false},
+		{"size", "", true},
+	}
+	for _, test := range tests {
+		sorted := pages()
+		err := sortPages(sorted, test.by)
+		if (err != nil) != test.wantErr {
+			t.Errorf("sortPages by %s returned error %v", test.by, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var titles []string
+		for _, page := range sorted {
+			titles = append(titles, page.Title)
+		}
+		if got := strings.Join(titles, " "); got != test.want {
+			t.Errorf("sortPages by %s = %s, want %s", test.by, got, test.want)
+		}
+	}
+}
+
+func TestLinkNeighbours(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]string
+		want  map[string]string // the titles of the previous and next page of each page in docs
+	}{
+		{
+			"by date",
+			map[string]string{
+				"docs/a.md": "---\ntitle: A\ndate: 2024-01-01\n---\n",
+				"docs/b.md": "---\ntitle: B\ndate: 2024-01-03\n---\n",
+				"docs/c.md": "---\ntitle: C\ndate: 2024-01-02\n---\n",
+			},
+			map[string]string{"B": "- C", "C": "B A", "A": "C -"},
+		},
+		{
+			"by weight",
+			map[string]string{
+				"docs/a.md": "---\ntitle: A\nweight: 2\n---\n",
+				"docs/b.md": "---\ntitle: B\nweight: 1\n---\n",
+				"docs/c.md": "---\ntitle: C\n---\n",
+			},
+			map[string]string{"B": "- A", "A": "B C", "C": "A -"},
+		},
+		{
+			"by the sort of the section page",
+			map[string]string{
+				"docs.md":   "---\ntitle: Docs\nsort: title\n---\n",
+				"docs/a.md": "---\ntitle: A\nweight: 2\n---\n",
+				"docs/b.md": "---\ntitle: B\nweight: 1\n---\n",
+			},
+			map[string]string{"A": "- B", "B": "A -"},
+		},
+		{
+			"overridden in front matter",
+			map[string]string{
+				"docs/a.md": "---\ntitle: A\nweight: 1\nprev: docs/b\nnext: false\n---\n",
+				"docs/b.md": "---\ntitle: B\nweight: 2\n---\n",
+			},
+			map[string]string{"A": "B -", "B": "A -"},
+		},
+	}
+	for _, test := range tests {
+		b, _ := testBuilder(testSource(test.pages), "")
+		if err := b.Load(context.Background()); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		for _, page := range b.Pages() {
+			if page.Section != "docs" {
+				continue
+			}
+
+			prev, next := "-", "-"
+			if page.Prev != nil {
+				prev = page.Prev.Title
+			}
+			if page.Next != nil {
+				next = page.Next.Title
+			}
+			if got := prev + " " + next; got != test.want[page.Title] {
+				t.Errorf("%s: %s links to %s, want %s", test.name, page.Title, got, test.want[page.Title])
+			}
+		}
+	}
+}
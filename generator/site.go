@@ -0,0 +1,411 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// Site holds every page loaded from pagesDir, so that rendering one page can
+// look at any other, along with the pages goose generates from them.
+type Site struct {
+	Pages      []*Page
+	Taxonomies map[string]*Taxonomy
+
+	bySource  map[string]*Page
+	generated []*Page
+
+	// fingerprint changes whenever a page would see a different .Site
+	fingerprint string
+}
+
+// Kinds of pages.
+const (
+	KindPage     = "page"     // a Markdown file from pagesDir
+	KindSection  = "section"  // a Markdown file listing its directory, e.g. blog.md
+	KindTaxonomy = "taxonomy" // the list of terms of a taxonomy, e.g. /tags/
+	KindTerm     = "term"     // the pages with a term, e.g. /tags/go/
+)
+
+// Page is a single Markdown file from pagesDir, or a page goose generates,
+// along with where it ends up in the build.
+type Page struct {
+	Kind       string
+	Title      string
+	URL        string
+	Section    string
+	SourcePath string
+	OutputPath string
+	Meta       map[string]interface{}
+	Content    string
+
+	// Date falls back to PublishDate and the other way around, and Lastmod
+	// to Date and then the time the file was last written.
+	Date        time.Time
+	PublishDate time.Time
+	Lastmod     time.Time
+	ExpiryDate  time.Time
+
+	// Summary is the summary front matter, the text before <!--more-->, or
+	// the first words of the page, and Truncated tells whether there is
+	// more to the page than that. ReadingTime is in minutes.
+	Summary     string
+	Truncated   bool
+	WordCount   int
+	ReadingTime int
+
+	// Terms maps each taxonomy to the terms of the page, in front matter
+	// order.
+	Terms map[string][]*Term
+
+	// Related lists the pages with the most in common with this one, best
+	// match first; see Config.RelatedWeights.
+	Related []*Page
+
+	// Prev and Next are the pages before and after this one in its section,
+	// or the ones named by the prev and next front matter.
+	Prev, Next *Page
+
+	// Pages lists the pages a section or term page lists, and Paginator
+	// the ones on this page when the list is paginated.
+	Pages     []*Page
+	Paginator *Paginator
+
+	// Taxonomy is set on taxonomy and term pages, and Term on term pages.
+	Taxonomy *Taxonomy
+	Term     *Term
+
+	// files read while loading the page, see depGraph
+	deps []string
+
+	// template is used when the front matter does not name one, e.g. the
+	// list template of a section page
+	template string
+
+	// fragment pages only hold the items of a paginated list
+	fragment bool
+
+	// html is the HTML rendered from the Markdown of the page, for feeds
+	html string
+}
+
+func newSite(pages []*Page) *Site {
+	site := &Site{bySource: make(map[string]*Page)}
+	for _, page := range pages {
+		site.add(page)
+	}
+	return site
+}
+
+// add inserts page, replacing any page loaded from the same source.
+func (s *Site) add(page *Page) {
+	s.remove(page.SourcePath)
+
+	s.bySource[page.SourcePath] = page
+	s.Pages = append(s.Pages, page)
+	sort.Slice(s.Pages, func(i, j int) bool {
+		return s.Pages[i].SourcePath < s.Pages[j].SourcePath
+	})
+}
+
+func (s *Site) remove(source string) {
+	source = filepath.Clean(source)
+	if _, ok := s.bySource[source]; !ok {
+		return
+	}
+
+	delete(s.bySource, source)
+	for i, page := range s.Pages {
+		if page.SourcePath == source {
+			s.Pages = append(s.Pages[:i], s.Pages[i+1:]...)
+			break
+		}
+	}
+}
+
+// page returns the page loaded from source, or nil if there is none.
+func (s *Site) page(source string) *Page {
+	return s.bySource[filepath.Clean(source)]
+}
+
+// generatedPage returns the generated page with the given source, or nil.
+func (s *Site) generatedPage(source string) *Page {
+	for _, page := range s.generated {
+		if page.SourcePath == source {
+			return page
+		}
+	}
+	return nil
+}
+
+// linkedSources returns the source paths of the pages a page shows through
+// Prev, Next and Related, which its output depends on besides its own files.
+// Prev and Next always come first, and are empty when there is no such page.
+func linkedSources(page *Page) []string {
+	sources := []string{"", ""}
+	if page.Prev != nil {
+		sources[0] = page.Prev.SourcePath
+	}
+	if page.Next != nil {
+		sources[1] = page.Next.SourcePath
+	}
+	for _, related := range page.Related {
+		sources = append(sources, related.SourcePath)
+	}
+	return sources
+}
+
+// siteFingerprint sums up everything a template sees of the site through
+// .Site: every field of every page, and the terms of every taxonomy with the
+// pages that have them.
+func siteFingerprint(s *Site) string {
+	var sum strings.Builder
+	for _, page := range s.Pages {
+		fmt.Fprintf(&sum, "page %q %q %q %q %q %q\n",
+			page.SourcePath, page.Kind, page.Title, page.URL, page.Section, page.OutputPath)
+		fmt.Fprintf(&sum, "  dates %v %v %v %v\n", page.Date, page.PublishDate, page.Lastmod, page.ExpiryDate)
+		fmt.Fprintf(&sum, "  summary %q %v %d %d\n", page.Summary, page.Truncated, page.WordCount, page.ReadingTime)
+		fmt.Fprintf(&sum, "  meta %s\n", hashString(fmt.Sprintf("%v", page.Meta)))
+		fmt.Fprintf(&sum, "  content %s\n", hashString(page.Content))
+		fmt.Fprintf(&sum, "  linked %q\n", linkedSources(page))
+		for _, listed := range page.Pages {
+			fmt.Fprintf(&sum, "  lists %q\n", listed.SourcePath)
+		}
+	}
+
+	names := make([]string, 0, len(s.Taxonomies))
+	for name := range s.Taxonomies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, term := range s.Taxonomies[name].Terms {
+			fmt.Fprintf(&sum, "term %q %q %q %q\n", name, term.Name, term.Slug, term.URL)
+			for _, page := range term.Pages {
+				fmt.Fprintf(&sum, "  %q\n", page.SourcePath)
+			}
+		}
+	}
+
+	return hashString(sum.String())
+}
+
+// allPages returns the pages from pagesDir followed by the generated ones.
+func (s *Site) allPages() []*Page {
+	return append(append([]*Page{}, s.Pages...), s.generated...)
+}
+
+// generatePages links every section page to its pages and every page to its
+// neighbours and related pages, and works out every page goose generates
+// from the loaded pages, replacing the ones from an earlier build. It returns
+// the section pages and the generated pages, which list other pages and so
+// are rendered again whenever any page changes.
+func (b *Builder) generatePages() []*Page {
+	sections := b.linkSections()
+	b.linkNeighbours(sections)
+	generated := b.taxonomyPages()
+	b.relatedPages()
+
+	for _, page := range append(append([]*Page{}, sections...), generated...) {
+		page.Paginator = nil
+		if page.Kind == KindSection || page.Kind == KindTerm {
+			generated = append(generated, b.paginate(page)...)
+		}
+	}
+
+	b.site.generated = generated
+	b.site.fingerprint = siteFingerprint(b.site)
+	return append(sections, generated...)
+}
+
+// loadSite runs the first phase of a build: every Markdown file is read and
+// its front matter extracted, without rendering anything.
+// Pages that fail to load are recorded in the build report and left out.
+func (b *Builder) loadSite(ctx context.Context, paths []string) *Site {
+	pages := make([]*Page, len(paths))
+	errs := b.runJobs(ctx, len(paths), func(i int, logs io.Writer) error {
+		var err error
+		pages[i], err = b.loadPage(paths[i], logs)
+		return err
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			b.report.fail(paths[i], err)
+		}
+	}
+
+	var loaded []*Page
+	for _, page := range pages {
+		if page != nil {
+			loaded = append(loaded, page)
+		}
+	}
+
+	return newSite(loaded)
+}
+
+// loadPage reads a Markdown file and works out its output path and URL. It
+// returns nil for pages that are not part of the build, such as drafts.
+func (b *Builder) loadPage(path string, logs io.Writer) (*Page, error) {
+	cfg := b.config
+	logger := log.New(logs, "", log.LstdFlags)
+	path = filepath.Clean(path)
+	deps := []string{path}
+
+	code, err := b.readFile(path)
+	if err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageFrontMatter, path, err)
+	}
+
+	relPath, err := filepath.Rel(b.pagesDir, path)
+	if err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageWrite, path, err)
+	}
+
+	metadata, err := helpers.ExtractMetadata(
+		string(code),
+		helpers.MarkdownConfig{
+			Theme:                                 cfg.SyntaxHighlightingStyle,
+			SyntaxHighlightingUseCustomBackground: false,
+			SyntaxHighlightingCustomBackground:    "",
+			EnableCodeBlockLineNumbers:            cfg.EnableCodeBlockLineNumbers,
+			EnableEmoji:                           cfg.EnableEmoji,
+		},
+		b.defaultMetadata,
+	)
+	if err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageFrontMatter, path, err)
+	}
+
+	// hooks see the page before anything is worked out from its front matter
+	page := &Page{Kind: KindPage, SourcePath: path, Meta: metadata, Content: string(code)}
+	if err := b.afterFrontMatter(page); err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageFrontMatter, path, err)
+	}
+	metadata = page.Meta
+
+	if !cfg.IncludeDrafts && metadata["draft"] == true { // skip draft
+		logger.Printf("Skipping draft %s.\n", path)
+		b.skipPage(path, deps, logger)
+		return nil, nil
+	}
+
+	date, publishDate, lastmod, expiryDate, err := b.pageDates(metadata)
+	if err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageFrontMatter, path, err)
+	}
+
+	if reason := b.unpublished(publishDate, expiryDate, time.Now()); reason != "" {
+		logger.Printf("Skipping %s, as %s.\n", path, reason)
+		b.skipPage(path, deps, logger)
+		return nil, nil
+	}
+
+	var section string
+	if dir := filepath.Dir(relPath); dir != "." {
+		section = strings.Split(filepath.ToSlash(dir), "/")[0]
+	}
+
+	link, err := b.permalink(relPath, section, metadata)
+	if err != nil {
+		b.skipPage(path, deps, logger)
+		return nil, newBuildError(path, stageFrontMatter, path, err)
+	}
+
+	baseName := filepath.Base(path)
+	ext := filepath.Ext(baseName)
+	nameWithoutExt := baseName[:len(baseName)-len(ext)]
+	outPath := filepath.Join(cfg.BuildDir, filepath.Dir(relPath), nameWithoutExt+".html")
+
+	if link != "" {
+		outPath = filepath.Join(cfg.BuildDir, filepath.FromSlash(urlFile(link)))
+	} else if cfg.PrettyURLs {
+		deps = append(deps, filepath.Join(b.pagesDir, filepath.Dir(relPath), nameWithoutExt, "index.md"))
+		if exists, err := b.isFile(filepath.Join(b.pagesDir, filepath.Dir(relPath), nameWithoutExt, "index.md")); exists &&
+			err == nil ||
+			exists {
+			b.report.warn(logger, newBuildError(path, stageWrite, path, fmt.Errorf(
+				"both \"%s\" and \"%s\" exist; skipping",
+				baseName,
+				filepath.Join(filepath.Dir(relPath), nameWithoutExt, "index.md"),
+			)))
+			b.skipPage(path, deps, logger)
+			return nil, nil
+		} else {
+			if nameWithoutExt != "index" {
+				outPath = filepath.Join(cfg.BuildDir, filepath.Dir(relPath), nameWithoutExt, "index.html")
+			}
+		}
+	}
+
+	var title string
+	if metadata["title"] != nil {
+		title = fmt.Sprintf("%v", metadata["title"])
+	}
+
+	url := link
+	if url == "" {
+		url = outputURL(outPath, cfg.BuildDir, cfg.PrettyURLs)
+	}
+
+	// without a lastmod or date, the page last changed when its file did
+	if lastmod.IsZero() {
+		if info, err := b.stat(path); err == nil {
+			lastmod = info.ModTime()
+		}
+	}
+
+	page.Title = title
+	page.URL = url
+	page.Section = section
+	page.OutputPath = outPath
+	page.Date = date
+	page.PublishDate = publishDate
+	page.Lastmod = lastmod
+	page.ExpiryDate = expiryDate
+	page.deps = deps
+	b.summarize(page)
+
+	return page, nil
+}
+
+// skipPage records a page that produces no output, removing whatever it
+// produced in an earlier build.
+func (b *Builder) skipPage(path string, deps []string, logger *log.Logger) {
+	if previousOutPath := b.deps.forget(path); previousOutPath != "" {
+		if err := b.output.Remove(b.outputName(previousOutPath)); err != nil {
+			logger.Printf("Error removing %s: %v\n", previousOutPath, err)
+		}
+	}
+
+	b.deps.record(path, "", deps)
+}
+
+// outputURL turns a path in buildDir into the root-relative URL it is served
+// at.
+func outputURL(outPath, buildDir string, prettyURLs bool) string {
+	relPath, err := filepath.Rel(buildDir, outPath)
+	if err != nil {
+		return ""
+	}
+
+	url := "/" + filepath.ToSlash(relPath)
+	if prettyURLs && (url == "/index.html" || strings.HasSuffix(url, "/index.html")) {
+		url = strings.TrimSuffix(url, "index.html")
+	}
+	return url
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sitemap writes a sitemap.xml listing every page of the site, and a
+// robots.txt pointing at it.
+type sitemap struct {
+	baseURL  string
+	sitemap  bool
+	robots   bool
+	disallow []string
+}
+
+func (sitemap) Name() string { return "sitemap" }
+
+func (h sitemap) PlannedOutputs(site *Site) []PlannedOutput {
+	var planned []PlannedOutput
+	if h.sitemap {
+		planned = append(planned, PlannedOutput{Name: "sitemap.xml", Source: "the sitemap setting"})
+	}
+	if h.robots {
+		planned = append(planned, PlannedOutput{Name: "robots.txt", Source: "the robotsTxt setting"})
+	}
+	return planned
+}
+
+func (h sitemap) AfterSite(site *Site, out FileWriter) error {
+	var errs []error
+	if h.sitemap {
+		urls := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+		for _, page := range site.allPages() {
+			if page.fragment || page.Meta["draft"] == true || page.Meta["sitemap"] == false {
+				continue
+			}
+
+			url := sitemapURL{Loc: h.baseURL + page.URL}
+			if lastmod := pageLastmod(page); !lastmod.IsZero() {
+				url.Lastmod = lastmod.Format(time.RFC3339)
+			}
+			if changefreq, ok := page.Meta["changefreq"].(string); ok {
+				url.Changefreq = changefreq
+			}
+			if priority, ok := frontMatterNumber(page.Meta["priority"]); ok {
+				url.Priority = strconv.FormatFloat(priority, 'f', -1, 64)
+			}
+			urls.URLs = append(urls.URLs, url)
+		}
+
+		sort.Slice(urls.URLs, func(i, j int) bool {
+			return urls.URLs[i].Loc < urls.URLs[j].Loc
+		})
+
+		output, err := xml.MarshalIndent(urls, "", "  ")
+		if err == nil {
+			err = out.WriteFile("sitemap.xml", append([]byte(xml.Header), output...))
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if h.robots {
+		var robots strings.Builder
+		robots.WriteString("User-agent: *\n")
+		if len(h.disallow) == 0 {
+			robots.WriteString("Disallow:\n")
+		}
+		for _, path := range h.disallow {
+			fmt.Fprintf(&robots, "Disallow: %s\n", path)
+		}
+		if h.sitemap {
+			fmt.Fprintf(&robots, "\nSitemap: %s/sitemap.xml\n", h.baseURL)
+		}
+
+		if err := out.WriteFile("robots.txt", []byte(robots.String())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pageLastmod returns when a page last changed. A list changes with the
+// pages it lists.
+func pageLastmod(page *Page) time.Time {
+	lastmod := page.Lastmod
+	for _, listed := range page.Pages {
+		if listed.Lastmod.After(lastmod) {
+			lastmod = listed.Lastmod
+		}
+	}
+	if page.Taxonomy != nil && page.Term == nil {
+		for _, term := range page.Taxonomy.Terms {
+			for _, listed := range term.Pages {
+				if listed.Lastmod.After(lastmod) {
+					lastmod = listed.Lastmod
+				}
+			}
+		}
+	}
+	return lastmod
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	Lastmod    string `xml:"lastmod,omitempty"`
+	Changefreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Paths inside the builder keep the SourceDir prefix, e.g.
+// "source/pages/index.md", so they read the same in logs and errors whatever
+// the source is. These helpers map them onto Config.Source.
+
+// sourceName returns the name of path inside the source filesystem.
+func (b *Builder) sourceName(path string) (string, error) {
+	relPath, err := filepath.Rel(b.config.SourceDir, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+func (b *Builder) readFile(path string) ([]byte, error) {
+	name, err := b.sourceName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fs.ReadFile(b.source, name)
+
+	// report the path the way the rest of the build knows it
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = path
+	}
+	return content, err
+}
+
+func (b *Builder) stat(path string) (fs.FileInfo, error) {
+	name, err := b.sourceName(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(b.source, name)
+}
+
+func (b *Builder) isFile(path string) (bool, error) {
+	info, err := b.stat(path)
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+func (b *Builder) isDir(path string) (bool, error) {
+	info, err := b.stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// walkFiles calls fn for every file under dir, in lexical order.
+func (b *Builder) walkFiles(dir string, fn func(path string) error) error {
+	root, err := b.sourceName(dir)
+	if err != nil {
+		return err
+	}
+
+	return fs.WalkDir(b.source, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		return fn(filepath.Join(b.config.SourceDir, filepath.FromSlash(name)))
+	})
+}
+
+// outputName returns the name of outPath, a path in BuildDir, inside the
+// output.
+func (b *Builder) outputName(outPath string) string {
+	relPath, err := filepath.Rel(b.config.BuildDir, outPath)
+	if err != nil {
+		return filepath.ToSlash(outPath)
+	}
+	return filepath.ToSlash(relPath)
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/radeeyate/goose/helpers"
+)
+
+// moreMarker ends the summary of a page in its Markdown.
+const moreMarker = "<!--more-->"
+
+// placeholderRe matches the placeholders of a page, which are left out of its
+// text as they are only filled in when it is rendered.
+var placeholderRe = regexp.MustCompile(`{{[^}]*}}`)
+
+// summarize works out the summary, word count and reading time of a page,
+// and adds them to its front matter as summary, wordCount and readingTime
+// unless it sets those itself.
+func (b *Builder) summarize(page *Page) {
+	cfg := b.config
+	mdConfig := helpers.MarkdownConfig{
+		Theme:       cfg.SyntaxHighlightingStyle,
+		EnableEmoji: cfg.EnableEmoji,
+	}
+
+	text := func(markdown string) []string {
+		return strings.Fields(placeholderRe.ReplaceAllString(helpers.MarkdownText(markdown, mdConfig), ""))
+	}
+
+	words := text(page.Content)
+	page.WordCount = len(words)
+	if cfg.WordsPerMinute > 0 {
+		page.ReadingTime = (len(words) + cfg.WordsPerMinute - 1) / cfg.WordsPerMinute
+	}
+
+	// the summary front matter wins, then the text before <!--more-->, and
+	// then the first words of the page
+	if summary, ok := page.Meta["summary"]; ok {
+		page.Summary = fmt.Sprintf("%v", summary)
+		page.Truncated = true
+	} else if before, _, found := strings.Cut(page.Content, moreMarker); found {
+		page.Summary = strings.Join(text(before), " ")
+		page.Truncated = true
+	} else if cfg.SummaryLength > 0 && len(words) > cfg.SummaryLength {
+		page.Summary = strings.Join(words[:cfg.SummaryLength], " ") + "…"
+		page.Truncated = true
+	} else {
+		page.Summary = strings.Join(words, " ")
+	}
+
+	for key, value := range map[string]interface{}{
+		"summary":     page.Summary,
+		"wordCount":   page.WordCount,
+		"readingTime": page.ReadingTime,
+	} {
+		if _, ok := page.Meta[key]; !ok {
+			page.Meta[key] = value
+		}
+	}
+}
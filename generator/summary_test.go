@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"io"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SummaryLength = 5
+	cfg.WordsPerMinute = 2
+	cfg.Log = io.Discard
+	b := New(cfg)
+
+	tests := []struct {
+		name      string
+		content   string
+		meta      map[string]interface{}
+		summary   string
+		truncated bool
+		wordCount int
+		reading   int
+	}{
+		{"short", "Just **three** words.", nil, "Just three words.", false, 3, 2},
+		{"long", "One two three four five six seven.", nil, "One two three four five…", true, 7, 4},
+		{"more marker", "# Intro\n\nFirst part.\n\n<!--more-->\n\nSecond part.", nil, "Intro First part.", true, 5, 3},
+		{"front matter", "Some words here.", map[string]interface{}{"summary": "Given."}, "Given.", true, 3, 2},
+		{"placeholders", "Hello {{ name }} there.", nil, "Hello there.", false, 2, 1},
+	}
+	for _, test := range tests {
+		meta := map[string]interface{}{}
+		for key, value := range test.meta {
+			meta[key] = value
+		}
+		page := &Page{Content: test.content, Meta: meta}
+		b.summarize(page)
+
+		if page.Summary != test.summary || page.Truncated != test.truncated {
+			t.Errorf("%s: summary %q (truncated %v), want %q (truncated %v)", test.name, page.Summary, page.Truncated, test.summary, test.truncated)
+		}
+		if page.WordCount != test.wordCount || page.ReadingTime != test.reading {
+			t.Errorf("%s: %d words and %d minutes, want %d and %d", test.name, page.WordCount, page.ReadingTime, test.wordCount, test.reading)
+		}
+		if page.Meta["summary"] != page.Summary {
+			t.Errorf("%s: the summary front matter is %v, want %q", test.name, page.Meta["summary"], page.Summary)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Taxonomy groups pages by the terms they list in one front matter key, e.g.
+// tags.
+type Taxonomy struct {
+	Name  string
+	URL   string
+	Terms []*Term
+}
+
+// Term is a single value of a taxonomy along with the pages that have it.
+type Term struct {
+	Name  string
+	Slug  string
+	URL   string
+	Pages []*Page
+}
+
+const (
+	taxonomyTemplate = "taxonomy.html"
+	termTemplate     = "term.html"
+)
+
+// taxonomyPages collects the terms of every page into b.site.Taxonomies and
+// returns an index page for each taxonomy and a listing page for each term.
+// Taxonomies no page uses are left out.
+func (b *Builder) taxonomyPages() []*Page {
+	b.site.Taxonomies = make(map[string]*Taxonomy)
+
+	var pages []*Page
+	for _, name := range b.config.Taxonomies {
+		taxonomy := &Taxonomy{Name: name}
+		bySlug := make(map[string]*Term)
+
+		for _, page := range b.site.Pages {
+			if page.Terms == nil {
+				page.Terms = make(map[string][]*Term)
+			}
+			page.Terms[name] = nil
+
+			for _, value := range frontMatterList(page.Meta[name]) {
+				slug := slugify(value)
+				if slug == "" {
+					continue
+				}
+
+				term, ok := bySlug[slug]
+				if !ok {
+					term = &Term{Name: value, Slug: slug}
+					bySlug[slug] = term
+					taxonomy.Terms = append(taxonomy.Terms, term)
+				}
+
+				if len(term.Pages) == 0 || term.Pages[len(term.Pages)-1] != page {
+					term.Pages = append(term.Pages, page)
+					page.Terms[name] = append(page.Terms[name], term)
+				}
+			}
+		}
+
+		if len(taxonomy.Terms) == 0 {
+			continue
+		}
+
+		sort.Slice(taxonomy.Terms, func(i, j int) bool {
+			return taxonomy.Terms[i].Slug < taxonomy.Terms[j].Slug
+		})
+
+		index := b.generatedPage(KindTaxonomy, name, "/"+slugify(name)+"/", taxonomyTemplate)
+		index.Title = titleCase(name)
+		index.Taxonomy = taxonomy
+		taxonomy.URL = index.URL
+
+		var list strings.Builder
+		fmt.Fprintf(&list, "# %s\n\n", index.Title)
+		for _, term := range taxonomy.Terms {
+			termPage := b.generatedPage(KindTerm, name+"/"+term.Slug, "/"+slugify(name)+"/"+term.Slug+"/", termTemplate)
+			termPage.Title = term.Name
+			termPage.Taxonomy = taxonomy
+			termPage.Term = term
+			term.URL = termPage.URL
+
+			sortPages(term.Pages, sortByDate)
+			termPage.Pages = term.Pages
+			termPage.Content = pageList(term.Name, term.Pages)
+
+			fmt.Fprintf(&list, "- [%s](%s) (%d)\n", term.Name, term.URL, len(term.Pages))
+			pages = append(pages, termPage)
+		}
+		index.Content = list.String()
+
+		pages = append(pages, index)
+		b.site.Taxonomies[name] = taxonomy
+	}
+
+	for _, page := range pages {
+		page.Meta["title"] = page.Title
+	}
+
+	return pages
+}
+
+// generatedPage returns a page of kind served at url. It uses the named
+// template when there is one, and falls back to the default template
+// otherwise, so a listing still shows up without any template work.
+func (b *Builder) generatedPage(kind, name, url, template string) *Page {
+	meta := make(map[string]interface{}, len(b.defaultMetadata)+1)
+	for key, value := range b.defaultMetadata {
+		meta[key] = value
+	}
+
+	outPath := filepath.Join(b.config.BuildDir, filepath.FromSlash(urlFile(url)))
+	page := &Page{
+		Kind:       kind,
+		URL:        outputURL(outPath, b.config.BuildDir, b.config.PrettyURLs),
+		SourcePath: kind + ":" + name,
+		OutputPath: outPath,
+		Meta:       meta,
+	}
+
+	templatePath := filepath.Join(b.templatesDir, template)
+	page.deps = append(page.deps, templatePath)
+	if exists, err := b.isFile(templatePath); exists && err == nil {
+		page.template = template
+	}
+
+	return page
+}
+
+// pageList is the Markdown of a generated page that lists pages, for sites
+// without a dedicated template.
+func pageList(title string, pages []*Page) string {
+	var list strings.Builder
+	fmt.Fprintf(&list, "# %s\n\n", title)
+	for _, page := range pages {
+		fmt.Fprintf(&list, "- [%s](%s)\n", page.Title, page.URL)
+	}
+	return list.String()
+}
+
+// frontMatterList returns a front matter value that is either a list or a
+// single value as a list of strings.
+func frontMatterList(value interface{}) []string {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			list = append(list, fmt.Sprintf("%v", item))
+		}
+		return list
+	default:
+		return []string{fmt.Sprintf("%v", value)}
+	}
+}
+
+// slugify turns a term into a URL path segment: lower case, with runs of
+// anything but letters and digits replaced by a dash.
+func slugify(s string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+func titleCase(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -4,6 +4,7 @@ go 1.23.4
 
 require (
 	github.com/alecthomas/chroma/v2 v2.16.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/gohugoio/hugo-goldmark-extensions/extras v0.3.0
 	github.com/spf13/viper v1.20.1
 	github.com/tdewolff/minify/v2 v2.23.1
@@ -12,10 +13,10 @@ require (
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
 	github.com/yuin/goldmark-meta v1.1.0
 	golang.org/x/net v0.39.0
+	golang.org/x/sys v0.32.0
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/sagikazarmark/locafero v0.9.0 // indirect
@@ -24,9 +25,7 @@ require (
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
-	go.uber.org/atomic v1.11.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -35,7 +34,6 @@ require (
 	github.com/dlclark/regexp2 v1.11.5 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/spf13/cobra v1.9.1
-	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/tdewolff/parse/v2 v2.7.23 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -3,8 +3,10 @@ package helpers
 import (
 	"bytes"
 	"fmt" // <-- Add fmt import if not already there
-	"io"  // <-- Add io import
+	"html"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/alecthomas/chroma/v2"
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
@@ -13,8 +15,10 @@ import (
 	emoji "github.com/yuin/goldmark-emoji"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
 	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
 )
 
 type MarkdownConfig struct {
@@ -41,6 +45,21 @@ func IsDir(path string) (bool, error) {
 	return info.IsDir(), nil
 }
 
+// IsWithin reports whether path is dir or lies inside it.
+func IsWithin(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	return absPath == absDir || strings.HasPrefix(absPath, absDir+string(filepath.Separator))
+}
+
 func RemoveDuplicates(input []interface{}) []interface{} {
 	seen := make(map[interface{}]bool)
 	result := []interface{}{}
@@ -63,15 +82,149 @@ func RenderMarkdown(input string, config MarkdownConfig) (string, error) {
 	return buf.String(), nil
 }
 
+// Heading is an entry of a table of contents, with the headings below it.
+type Heading struct {
+	Level    int
+	ID       string
+	Title    string
+	Children []*Heading
+}
+
+// RenderMarkdownTOC renders input like RenderMarkdown, and also returns its
+// headings from level minLevel to maxLevel, nested by level.
+func RenderMarkdownTOC(input string, config MarkdownConfig, minLevel, maxLevel int) (string, []*Heading, error) {
+	source := []byte(input)
+	mdRenderer := generateMarkdownRenderer(config)
+	doc := mdRenderer.Parser().Parse(text.NewReader(source))
+
+	var toc []*Heading
+	var open []*Heading // the last heading of each level above the current one
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level < minLevel || heading.Level > maxLevel {
+			return ast.WalkSkipChildren, nil
+		}
+
+		entry := &Heading{Level: heading.Level, Title: headingText(heading, source)}
+		if id, ok := heading.AttributeString("id"); ok {
+			if id, ok := id.([]byte); ok {
+				entry.ID = string(id)
+			}
+		}
+
+		for len(open) > 0 && open[len(open)-1].Level >= entry.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			toc = append(toc, entry)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		open = append(open, entry)
+
+		return ast.WalkSkipChildren, nil
+	})
+
+	var buf bytes.Buffer
+	if err := mdRenderer.Renderer().Render(&buf, source, doc); err != nil {
+		return "", nil, fmt.Errorf("markdown conversion failed: %w", err)
+	}
+	return buf.String(), toc, nil
+}
+
+// headingText returns the plain text of a heading.
+func headingText(n ast.Node, source []byte) string {
+	var title strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			switch n := n.(type) {
+			case *ast.Text:
+				title.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() {
+					title.WriteByte(' ')
+				}
+			case *ast.String:
+				title.Write(n.Value)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return title.String()
+}
+
+// MarkdownText returns the plain text of input, without its front matter,
+// markup, code blocks or raw HTML. Blocks are separated by newlines.
+func MarkdownText(input string, config MarkdownConfig) string {
+	source := []byte(input)
+	doc := generateMarkdownRenderer(config).Parser().Parse(text.NewReader(source))
+
+	var plain strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				plain.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() || n.HardLineBreak() {
+					plain.WriteByte(' ')
+				}
+			}
+		case *ast.String:
+			if entering {
+				plain.Write(n.Value)
+			}
+		default:
+			if !entering && n.Type() == ast.TypeBlock && plain.Len() > 0 {
+				plain.WriteByte('\n')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(plain.String())
+}
+
+// TOCHTML renders a table of contents as nested lists of links to the
+// headings, inside a <nav class="toc">. It returns "" for an empty one.
+func TOCHTML(toc []*Heading) string {
+	if len(toc) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	var list func(headings []*Heading)
+	list = func(headings []*Heading) {
+		buf.WriteString("<ul>")
+		for _, heading := range headings {
+			fmt.Fprintf(&buf, `<li><a href="#%s">%s</a>`, html.EscapeString(heading.ID), html.EscapeString(heading.Title))
+			if len(heading.Children) > 0 {
+				list(heading.Children)
+			}
+			buf.WriteString("</li>")
+		}
+		buf.WriteString("</ul>")
+	}
+
+	buf.WriteString(`<nav class="toc">`)
+	list(toc)
+	buf.WriteString("</nav>")
+	return buf.String()
+}
+
 func ExtractMetadata(
 	input string,
 	config MarkdownConfig,
 	defaultMeta map[string]interface{},
-) map[string]interface{} {
+) (map[string]interface{}, error) {
 	context := parser.NewContext()
 	mdRenderer := generateMarkdownRenderer(config)
-	_ = mdRenderer.Convert([]byte(input), io.Discard, parser.WithContext(context))
-	pageMeta := meta.Get(context)
+	// front matter is collected while parsing, so there is no need to render
+	mdRenderer.Parser().Parse(text.NewReader([]byte(input)), parser.WithContext(context))
+	pageMeta, err := meta.TryGet(context)
 
 	finalMeta := make(map[string]interface{})
 	if defaultMeta != nil {
@@ -86,18 +239,15 @@ func ExtractMetadata(
 		}
 	}
 
-	return finalMeta
+	return finalMeta, err
 }
 
 func generateMarkdownRenderer(config MarkdownConfig) goldmark.Markdown {
 	var highlightingConfig map[chroma.TokenType]string
-	fmt.Println(config.SyntaxHighlightingUseCustomBackground)
 	if config.SyntaxHighlightingUseCustomBackground {
-		fmt.Println(config.SyntaxHighlightingCustomBackground)
 		highlightingConfig = map[chroma.TokenType]string{
 			chroma.Background: config.SyntaxHighlightingCustomBackground,
 		}
-		fmt.Println(highlightingConfig)
 	}
 
 	extensions := []goldmark.Extender{